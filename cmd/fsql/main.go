@@ -1,19 +1,39 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/kashav/fsql"
 	"github.com/kashav/fsql/meta"
+	"github.com/kashav/fsql/query"
 	"github.com/kashav/fsql/terminal"
+	"github.com/kashav/fsql/transform"
 )
 
 var options struct {
-	version bool
+	version    bool
+	jobs       int
+	walkers    int
+	unordered  bool
+	ignoreVCS  bool
+	maxDepth   int
+	follow     bool
+	xdev       bool
+	onError    string
+	timeout    time.Duration
+	stats      bool
+	minDepth   int
+	cache      bool
+	purgeCache bool
+
+	maxContentSize string
+	binary         bool
 }
 
 func readInput() string {
@@ -33,6 +53,36 @@ func main() {
 	flag.BoolVar(&options.version, "version", false, "print version and exit")
 	flag.BoolVar(&options.version, "v", false,
 		"print version and exit (shorthand)")
+	flag.IntVar(&options.jobs, "j", query.DefaultOptions().Jobs,
+		"number of files to evaluate concurrently")
+	flag.IntVar(&options.walkers, "walkers", query.DefaultOptions().Walkers,
+		"number of directories to read concurrently")
+	flag.BoolVar(&options.unordered, "unordered", false,
+		"print results as soon as they're found, in no particular order")
+	flag.BoolVar(&options.ignoreVCS, "ignore-vcs", false,
+		"exclude files ignored by git (.gitignore, .ignore and .fsqlignore files)")
+	flag.BoolVar(&options.follow, "L", false,
+		"follow symbolic links to directories")
+	flag.BoolVar(&options.xdev, "xdev", false,
+		"don't descend into directories on other filesystems")
+	flag.StringVar(&options.onError, "on-error", "fail",
+		"how to handle files which can't be read: skip, warn or fail")
+	flag.DurationVar(&options.timeout, "timeout", 0,
+		"stop each query after this long, with partial results (0 for no limit)")
+	flag.BoolVar(&options.stats, "stats", false,
+		"print statistics (e.g. files visited, time spent) to stderr after each query")
+	flag.IntVar(&options.maxDepth, "maxdepth", 0,
+		"maximum depth of the files in each source (0 for no limit)")
+	flag.IntVar(&options.minDepth, "mindepth", 0,
+		"minimum depth of the files in each source")
+	flag.BoolVar(&options.cache, "cache", false,
+		"read hashes from and write them to an on-disk cache")
+	flag.BoolVar(&options.purgeCache, "purge-cache", false,
+		"remove all cached hashes before running")
+	flag.StringVar(&options.maxContentSize, "max-content-size", "10MB",
+		"maximum number of bytes read from each file when searching content")
+	flag.BoolVar(&options.binary, "binary", false,
+		"search the content of binary files")
 	flag.Parse()
 
 	if options.version {
@@ -40,14 +90,81 @@ func main() {
 		os.Exit(0)
 	}
 
+	cache, err := setupCache()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if options.purgeCache && len(flag.Args()) == 0 {
+		os.Exit(0)
+	}
+
+	maxContentSize, err := transform.ParseSize(options.maxContentSize)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	onError, err := query.ParseErrorPolicy(options.onError)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	opts := query.DefaultOptions()
+	opts.Jobs = options.jobs
+	opts.Walkers = options.walkers
+	opts.Unordered = options.unordered
+	opts.IgnoreVCS = options.ignoreVCS
+	opts.MaxDepth = options.maxDepth
+	opts.FollowLinks = options.follow
+	opts.OneFilesystem = options.xdev
+	opts.OnError = onError
+	opts.Timeout = options.timeout
+	opts.MinDepth = options.minDepth
+	opts.MaxContentSize = maxContentSize
+	opts.Binary = options.binary
+	opts.HashCache = cache
+	if options.stats {
+		opts.Stats = func(stats *query.Stats) {
+			fmt.Fprint(os.Stderr, stats)
+		}
+	}
+
 	if len(flag.Args()) == 0 {
-		if err := terminal.Start(); err != nil {
+		if err := terminal.Start(opts); err != nil {
 			log.Fatal(err.Error())
 		}
 		os.Exit(0)
 	}
 
-	if err := fsql.Run(readInput()); err != nil {
+	if err := fsql.RunWithOptions(readInput(), opts); err != nil {
+		// Partial results were already printed, so we only summarize the files
+		// which were skipped (or why the query was interrupted).
+		var partial *query.ErrPartialResults
+		if errors.As(err, &partial) {
+			fmt.Fprintf(os.Stderr, "fsql: %v\n", err)
+			os.Exit(1)
+		}
 		log.Fatal(err.Error())
 	}
 }
+
+// setupCache purges the on-disk hash cache, as per the provided options, and
+// returns it if it's requested with -cache (or nil otherwise).
+func setupCache() (*transform.HashCache, error) {
+	dir, err := transform.DefaultHashCacheDir()
+	if err != nil {
+		// No cache directory is available on this machine (e.g. $HOME is
+		// unset), so we simply proceed without a cache.
+		return nil, nil
+	}
+	cache := &transform.HashCache{Dir: dir}
+
+	if options.purgeCache {
+		if err := cache.Purge(); err != nil {
+			return nil, err
+		}
+	}
+	if !options.cache {
+		return nil, nil
+	}
+	return cache, nil
+}
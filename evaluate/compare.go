@@ -1,8 +1,8 @@
 package evaluate
 
 import (
-	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,19 +17,13 @@ func cmpAlpha(o *Opts, a, b interface{}) (result bool, err error) {
 		result = a.(string) == b.(string)
 	case tokenizer.NotEquals:
 		result = a.(string) != b.(string)
-	case tokenizer.Like:
-		aStr, bStr := a.(string), b.(string)
-		if strings.HasPrefix(bStr, "%") && strings.HasSuffix(bStr, "%") {
-			result = strings.Contains(aStr, bStr[1:len(bStr)-1])
-		} else if strings.HasPrefix(bStr, "%") {
-			result = strings.HasSuffix(aStr, bStr[1:])
-		} else if strings.HasSuffix(bStr, "%") {
-			result = strings.HasPrefix(aStr, bStr[:len(bStr)-1])
-		} else {
-			result = strings.Contains(aStr, bStr)
+	case tokenizer.Like, tokenizer.ILike, tokenizer.RLike, tokenizer.Glob:
+		var re *regexp.Regexp
+		if re, err = o.pattern(b); err == nil {
+			result = re.MatchString(a.(string))
 		}
-	case tokenizer.RLike:
-		result = regexp.MustCompile(b.(string)).MatchString(a.(string))
+	case tokenizer.Contains:
+		result = strings.Contains(a.(string), b.(string))
 	case tokenizer.In:
 		switch t := b.(type) {
 		case map[interface{}]bool:
@@ -121,19 +115,44 @@ func cmpMode(o *Opts) (result bool, err error) {
 	return result, err
 }
 
+// cmpBool compares the boolean a with the provided value, which must be
+// `true` or `false` (case insensitive).
+func cmpBool(o *Opts, a bool) (result bool, err error) {
+	s, ok := o.Value.(string)
+	if !ok {
+		return false, &ErrUnsupportedType{o.Attribute, o.Value}
+	}
+	b, err := strconv.ParseBool(strings.ToLower(s))
+	if err != nil {
+		return false, err
+	}
+
+	switch o.Operator {
+	case tokenizer.Equals, tokenizer.Is:
+		result = a == b
+	case tokenizer.NotEquals:
+		result = a != b
+	default:
+		err = &ErrUnsupportedOperator{o.Attribute, o.Operator}
+	}
+	return result, err
+}
+
 // cmpHash computes the hash of the current file and compares it with the
 // provided value.
 func cmpHash(o *Opts) (result bool, err error) {
-	hashType := "SHA1"
+	var (
+		hashType = "SHA1"
+		limit    = int64(-1)
+	)
 	if len(o.Modifiers) > 0 {
 		hashType = o.Modifiers[0].Name
+		if _, limit, err = transform.ParseHashArgs(o.Modifiers[0].Arguments); err != nil {
+			return false, err
+		}
 	}
 
-	hashFunc := transform.FindHash(hashType)
-	if hashFunc == nil {
-		return false, fmt.Errorf("unexpected hash algorithm %s", hashType)
-	}
-	h, err := transform.ComputeHash(o.File, o.Path, hashFunc())
+	h, err := transform.HashFileContext(o.context(), o.File, o.Path, hashType, limit)
 	if err != nil {
 		return false, err
 	}
@@ -148,3 +167,22 @@ func cmpHash(o *Opts) (result bool, err error) {
 	}
 	return result, err
 }
+
+// cmpContent searches the contents of the current file for the provided
+// value.
+func cmpContent(o *Opts) (result bool, err error) {
+	switch o.Operator {
+	case tokenizer.Contains:
+		result, err = transform.ContentContainsContext(o.context(), o.File, o.Path,
+			o.Value.(string))
+	case tokenizer.RLike:
+		re, compileErr := o.pattern(o.Value)
+		if compileErr != nil {
+			return false, compileErr
+		}
+		result, err = transform.ContentMatchesContext(o.context(), o.File, o.Path, re)
+	default:
+		err = &ErrUnsupportedOperator{o.Attribute, o.Operator}
+	}
+	return result, err
+}
@@ -85,6 +85,55 @@ func TestCmpAlpha(t *testing.T) {
 			input:    Input{o: Opts{Operator: tokenizer.Like}, a: "a", b: "b"},
 			expected: Expected{result: false, err: nil},
 		},
+		{
+			input:    Input{o: Opts{Operator: tokenizer.Like}, a: "abc", b: "b"},
+			expected: Expected{result: false, err: nil},
+		},
+		{
+			input:    Input{o: Opts{Operator: tokenizer.Like}, a: "main.go", b: "main"},
+			expected: Expected{result: false, err: nil},
+		},
+
+		{
+			input:    Input{o: Opts{Operator: tokenizer.Like}, a: "a_c", b: "a_c"},
+			expected: Expected{result: true, err: nil},
+		},
+		{
+			input:    Input{o: Opts{Operator: tokenizer.Like}, a: "abc", b: "a_"},
+			expected: Expected{result: false, err: nil},
+		},
+		{
+			input:    Input{o: Opts{Operator: tokenizer.Like}, a: "abc", b: "a%b_"},
+			expected: Expected{result: true, err: nil},
+		},
+		{
+			input:    Input{o: Opts{Operator: tokenizer.Like, Escape: '!'}, a: "abc", b: "a!_c"},
+			expected: Expected{result: false, err: nil},
+		},
+		{
+			input:    Input{o: Opts{Operator: tokenizer.Like, Escape: '!'}, a: "a_c", b: "a!_%"},
+			expected: Expected{result: true, err: nil},
+		},
+		{
+			input:    Input{o: Opts{Operator: tokenizer.ILike}, a: "ABC", b: "a%"},
+			expected: Expected{result: true, err: nil},
+		},
+		{
+			input:    Input{o: Opts{Operator: tokenizer.Glob}, a: "a/b.go", b: "*.go"},
+			expected: Expected{result: false, err: nil},
+		},
+		{
+			input:    Input{o: Opts{Operator: tokenizer.Glob}, a: "a/b.go", b: "**/*.go"},
+			expected: Expected{result: true, err: nil},
+		},
+		{
+			input:    Input{o: Opts{Operator: tokenizer.Glob}, a: "src/a.go", b: "src/**/*.go"},
+			expected: Expected{result: true, err: nil},
+		},
+		{
+			input:    Input{o: Opts{Operator: tokenizer.Glob}, a: "a/b.go", b: "**.go"},
+			expected: Expected{result: false, err: nil},
+		},
 
 		{
 			input:    Input{o: Opts{Operator: tokenizer.RLike}, a: "a", b: ".*a.*"},
@@ -98,6 +147,15 @@ func TestCmpAlpha(t *testing.T) {
 			input:    Input{o: Opts{Operator: tokenizer.RLike}, a: "", b: "^$"},
 			expected: Expected{result: true, err: nil},
 		},
+
+		{
+			input:    Input{o: Opts{Operator: tokenizer.Contains}, a: "abc", b: "b"},
+			expected: Expected{result: true, err: nil},
+		},
+		{
+			input:    Input{o: Opts{Operator: tokenizer.Contains}, a: "abc", b: "d"},
+			expected: Expected{result: false, err: nil},
+		},
 		{
 			input:    Input{o: Opts{Operator: tokenizer.RLike}, a: "...", b: "[\\.]{3}"},
 			expected: Expected{result: true, err: nil},
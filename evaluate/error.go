@@ -1,11 +1,17 @@
 package evaluate
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/kashav/fsql/tokenizer"
 )
 
+// ErrUnknown is returned when a condition compares a NULL value (e.g. the
+// lines of a directory), so its result is unknown: neither the condition nor
+// its negation matches.
+var ErrUnknown = errors.New("comparison with NULL")
+
 // ErrUnsupportedAttribute represents an unsupported attribute error.
 type ErrUnsupportedAttribute struct {
 	Attribute string
@@ -1,11 +1,14 @@
 package evaluate
 
 import (
+	"context"
 	"os"
+	"regexp"
 	"strconv"
 	"time"
 
 	"github.com/kashav/fsql/tokenizer"
+	"github.com/kashav/fsql/transform"
 )
 
 // Opts represents a set of options used in the evaluate functions.
@@ -16,6 +19,30 @@ type Opts struct {
 	Modifiers []Modifier
 	Operator  tokenizer.TokenType
 	Value     interface{}
+
+	// Pattern is the compiled pattern of Value (see Compile), for operators
+	// which use one. If nil, the pattern is compiled when it's needed.
+	Pattern *regexp.Regexp
+
+	// Escape is the escape character of a LIKE or ILIKE pattern, or 0 if it
+	// doesn't have one.
+	Escape rune
+
+	// Computed holds attribute values which were computed by the caller (e.g.
+	// attributes which depend on other files), keyed by attribute name.
+	Computed map[string]interface{}
+
+	// Context interrupts reading the file (e.g. while hashing or searching
+	// it). If nil, the file is always read in full.
+	Context context.Context
+}
+
+// context returns the Context of o, or context.Background() if it's nil.
+func (o *Opts) context() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
 }
 
 // Modifier represents an attribute modifier.
@@ -26,6 +53,10 @@ type Modifier struct {
 
 // Evaluate runs the respective evaluate function for the provided options.
 func Evaluate(o *Opts) (bool, error) {
+	if value, ok := o.Computed[o.Attribute]; ok {
+		return evaluateValue(o, value)
+	}
+
 	switch o.Attribute {
 	case "name":
 		return evaluateName(o)
@@ -37,6 +68,11 @@ func Evaluate(o *Opts) (bool, error) {
 		return evaluateMode(o)
 	case "hash":
 		return evaluateHash(o)
+	case "content":
+		return evaluateContent(o)
+	case "lines", "words", "chars", "mime", "is_text", "is_binary", "width",
+		"height", "imgformat", "container", "path", "target", "device", "fstype":
+		return evaluateDefault(o)
 	}
 	return false, &ErrUnsupportedAttribute{o.Attribute}
 }
@@ -102,3 +138,58 @@ func evaluateMode(o *Opts) (bool, error) { return cmpMode(o) }
 
 // evaluateHash evaluates a Condition with attribute `hash`.
 func evaluateHash(o *Opts) (bool, error) { return cmpHash(o) }
+
+// evaluateContent evaluates a Condition with attribute `content`.
+func evaluateContent(o *Opts) (bool, error) {
+	if _, ok := o.Value.(string); !ok {
+		return false, &ErrUnsupportedType{o.Attribute, o.Value}
+	}
+	return cmpContent(o)
+}
+
+// evaluateDefault evaluates a Condition against the default format value of
+// its attribute (e.g. `lines` or `mime`).
+func evaluateDefault(o *Opts) (bool, error) {
+	value, err := transform.DefaultFormatValueContext(o.context(), o.Attribute,
+		o.Path, o.File)
+	if err != nil {
+		return false, err
+	}
+	return evaluateValue(o, value)
+}
+
+// evaluateValue evaluates a Condition against an already-computed attribute
+// value. Numeric values must be int64. A nil value (i.e. NULL) returns
+// ErrUnknown.
+func evaluateValue(o *Opts, value interface{}) (bool, error) {
+	switch value.(type) {
+	case int64:
+		var b interface{}
+		switch o.Value.(type) {
+		case float64:
+			b = int64(o.Value.(float64))
+		case map[interface{}]bool:
+			b = o.Value
+		case string:
+			n, err := strconv.ParseFloat(o.Value.(string), 10)
+			if err != nil {
+				return false, err
+			}
+			b = int64(n)
+		default:
+			return false, &ErrUnsupportedType{o.Attribute, o.Value}
+		}
+		return cmpNumeric(o, value, b)
+	case string:
+		switch o.Value.(type) {
+		case string, []string, map[interface{}]bool:
+			return cmpAlpha(o, value, o.Value)
+		}
+		return false, &ErrUnsupportedType{o.Attribute, o.Value}
+	case bool:
+		return cmpBool(o, value.(bool))
+	case nil:
+		return false, ErrUnknown
+	}
+	return false, &ErrUnsupportedType{o.Attribute, value}
+}
@@ -1,3 +1,65 @@
 package evaluate
 
-// TODO
+import (
+	"testing"
+
+	"github.com/kashav/fsql/tokenizer"
+)
+
+func TestEvaluate_Computed(t *testing.T) {
+	type Case struct {
+		opts     *Opts
+		expected bool
+		err      error
+	}
+
+	cases := []Case{
+		{
+			opts: &Opts{
+				Attribute: "dupcount",
+				Operator:  tokenizer.GreaterThan,
+				Value:     "1",
+				Computed:  map[string]interface{}{"dupcount": int64(2)},
+			},
+			expected: true,
+		},
+		{
+			opts: &Opts{
+				Attribute: "dupcount",
+				Operator:  tokenizer.Equals,
+				Value:     "1",
+				Computed:  map[string]interface{}{"dupcount": int64(2)},
+			},
+			expected: false,
+		},
+		{
+			opts: &Opts{
+				Attribute: "foo",
+				Operator:  tokenizer.Equals,
+				Value:     "bar",
+				Computed:  map[string]interface{}{"foo": "bar"},
+			},
+			expected: true,
+		},
+		{
+			opts: &Opts{
+				Attribute: "foo",
+				Operator:  tokenizer.Equals,
+				Value:     "bar",
+				Computed:  map[string]interface{}{"foo": nil},
+			},
+			expected: false,
+			err:      ErrUnknown,
+		},
+	}
+
+	for _, c := range cases {
+		actual, err := Evaluate(c.opts)
+		if err != c.err {
+			t.Fatalf("\nExpected %v\n     Got %v", c.err, err)
+		}
+		if actual != c.expected {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package evaluate
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/kashav/fsql/tokenizer"
+	"github.com/kashav/fsql/transform"
+)
+
+// Compile compiles the pattern of a condition which tests attribute with
+// operator against value, i.e. the regular expression of RLIKE or the
+// translation of LIKE, ILIKE or GLOB (see transform.GlobToRegexp). escape is
+// the escape character of a LIKE or ILIKE pattern (i.e. its ESCAPE clause), or
+// 0 if there isn't one. Returns nil if the condition doesn't use a pattern.
+//
+// The result may be passed through Opts.Pattern, so that the pattern is
+// compiled once per query rather than once per file.
+func Compile(attribute string, operator tokenizer.TokenType, value interface{},
+	escape rune) (*regexp.Regexp, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, nil
+	}
+
+	switch operator {
+	case tokenizer.RLike:
+		if attribute == "content" {
+			return transform.CompileContentPattern(s)
+		}
+		return regexp.Compile(s)
+	case tokenizer.Like:
+		return regexp.Compile(likeToRegexp(s, escape, false))
+	case tokenizer.ILike:
+		return regexp.Compile(likeToRegexp(s, escape, true))
+	case tokenizer.Glob:
+		return regexp.Compile("(?s)^" + transform.GlobToRegexp(s) + "$")
+	}
+	return nil, nil
+}
+
+// likeToRegexp translates the LIKE pattern into a regular expression, which is
+// case insensitive if fold is set. `%` matches any sequence of characters and
+// `_` matches any single character, unless they follow the escape character.
+// The pattern must match the whole value, so a pattern without any wildcards
+// is an exact match.
+func likeToRegexp(pattern string, escape rune, fold bool) string {
+	var b strings.Builder
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case escape != 0 && r == escape && i+1 < len(runes):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case r == '%':
+			b.WriteString(".*")
+		case r == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	flags := "(?s)"
+	if fold {
+		flags = "(?is)"
+	}
+	return flags + "^" + b.String() + "$"
+}
+
+// pattern returns the compiled pattern of value, which is the value of o,
+// compiling it if it wasn't passed through o.Pattern.
+func (o *Opts) pattern(value interface{}) (*regexp.Regexp, error) {
+	if o.Pattern != nil {
+		return o.Pattern, nil
+	}
+	re, err := Compile(o.Attribute, o.Operator, value, o.Escape)
+	if err == nil && re == nil {
+		err = &ErrUnsupportedType{o.Attribute, value}
+	}
+	return re, err
+}
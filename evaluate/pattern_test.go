@@ -0,0 +1,68 @@
+package evaluate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kashav/fsql/tokenizer"
+)
+
+func TestPattern_Compile(t *testing.T) {
+	type Input struct {
+		attribute string
+		operator  tokenizer.TokenType
+		value     interface{}
+		escape    rune
+	}
+
+	type Expected struct {
+		pattern string
+		err     bool
+	}
+
+	type Case struct {
+		input    Input
+		expected Expected
+	}
+
+	cases := []Case{
+		{input: Input{"name", tokenizer.RLike, `^\w+\.go$`, 0}, expected: Expected{pattern: `^\w+\.go$`}},
+		{input: Input{"name", tokenizer.RLike, "foo[a", 0}, expected: Expected{err: true}},
+		{input: Input{"content", tokenizer.RLike, "^foo", 0}, expected: Expected{pattern: "(?m)^foo"}},
+		{input: Input{"name", tokenizer.Like, "%.go", 0}, expected: Expected{pattern: `(?s)^.*\.go$`}},
+		{input: Input{"name", tokenizer.Like, "foo%", 0}, expected: Expected{pattern: "(?s)^foo.*$"}},
+		{input: Input{"name", tokenizer.Like, "a%b_c", 0}, expected: Expected{pattern: "(?s)^a.*b.c$"}},
+		{input: Input{"name", tokenizer.Like, "a.b", 0}, expected: Expected{pattern: `(?s)^a\.b$`}},
+		{input: Input{"name", tokenizer.Like, "100!%%", '!'}, expected: Expected{pattern: "(?s)^100%.*$"}},
+		{input: Input{"name", tokenizer.Like, "a!", '!'}, expected: Expected{pattern: "(?s)^a!$"}},
+		{input: Input{"name", tokenizer.ILike, "%.GO", 0}, expected: Expected{pattern: `(?is)^.*\.GO$`}},
+		{input: Input{"path", tokenizer.Glob, "src/*.[ch]", 0}, expected: Expected{pattern: `(?s)^src/[^/]*\.[ch]$`}},
+		{input: Input{"path", tokenizer.Glob, `**/[!.]?\*`, 0}, expected: Expected{pattern: `(?s)^(?:.*/)?[^.][^/]\*$`}},
+		{input: Input{"path", tokenizer.Glob, "src/**/*.go", 0}, expected: Expected{pattern: `(?s)^src/(?:.*/)?[^/]*\.go$`}},
+		{input: Input{"name", tokenizer.Glob, "[]a]", 0}, expected: Expected{pattern: `(?s)^[\]a]$`}},
+		{input: Input{"name", tokenizer.Glob, "[a", 0}, expected: Expected{pattern: `(?s)^\[a$`}},
+		{input: Input{"name", tokenizer.Equals, "foo", 0}, expected: Expected{}},
+		{input: Input{"name", tokenizer.RLike, []string{"foo"}, 0}, expected: Expected{}},
+	}
+
+	for _, c := range cases {
+		re, err := Compile(c.input.attribute, c.input.operator, c.input.value,
+			c.input.escape)
+		if (err != nil) != c.expected.err {
+			t.Fatalf("\nExpected error: %v\n     Got %v", c.expected.err, err)
+		}
+		actual := ""
+		if re != nil {
+			actual = re.String()
+		}
+		if !reflect.DeepEqual(c.expected.pattern, actual) {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected.pattern, actual)
+		}
+	}
+
+	// An invalid pattern is reported as an error, rather than a panic.
+	o := &Opts{Attribute: "name", Operator: tokenizer.RLike}
+	if _, err := cmpAlpha(o, "foo", "foo[a"); err == nil {
+		t.Fatalf("\nExpected an error\n     Got %v", err)
+	}
+}
@@ -2,24 +2,58 @@ package fsql
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/kashav/fsql/parser"
+	"github.com/kashav/fsql/query"
 )
 
 // Run parses the input and executes the resultant query.
 func Run(input string) error {
-	q, err := parser.Run(input)
+	return RunWithOptions(input, nil)
+}
+
+// RunWithOptions parses the input and executes the resultant query with opts.
+// If opts is nil, the default options are used.
+func RunWithOptions(input string, opts *query.Options) error {
+	return RunContext(context.Background(), input, opts)
+}
+
+// RunContext is RunWithOptions, where the query stops once ctx is done (or
+// opts.Timeout has elapsed). The results found by then are still printed,
+// and a *query.ErrPartialResults is returned.
+//
+// For `EXPLAIN SELECT ...`, the query's plan is printed instead of its results.
+// If opts.Stats is set, it's called with the query's statistics once the
+// results were printed.
+func RunContext(ctx context.Context, input string, opts *query.Options) error {
+	if opts != nil && opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	q, err := parser.RunContext(ctx, input, opts)
 	if err != nil {
 		return err
 	}
 
+	if q.Explain {
+		fmt.Print(q.Plan())
+		return nil
+	}
+
 	// Find length of the longest name to normalize name output.
 	var max = 0
 	var results = make([]map[string]interface{}, 0)
 
-	err = q.Execute(
+	// If files were skipped due to errors (or the query was interrupted), the
+	// results are still printed before returning the error.
+	execErr := q.ExecuteContext(ctx,
 		func(path string, info os.FileInfo, result map[string]interface{}) {
 			results = append(results, result)
 			if !q.HasAttribute("name") {
@@ -30,10 +64,12 @@ func Run(input string) error {
 			}
 		},
 	)
-	if err != nil {
-		return err
+	var partial *query.ErrPartialResults
+	if execErr != nil && !errors.As(execErr, &partial) {
+		return execErr
 	}
 
+	start := time.Now()
 	for _, result := range results {
 		var buf bytes.Buffer
 		for j, attribute := range q.Attributes {
@@ -43,7 +79,11 @@ func Run(input string) error {
 			if attribute == "name" {
 				format = fmt.Sprintf("%%-%ds", max)
 			}
-			buf.WriteString(fmt.Sprintf(format, result[attribute]))
+			value := result[attribute]
+			if value == nil {
+				value = "NULL"
+			}
+			buf.WriteString(fmt.Sprintf(format, value))
 			if j != len(q.Attributes)-1 {
 				buf.WriteString("\t")
 			}
@@ -51,5 +91,12 @@ func Run(input string) error {
 		fmt.Printf("%s\n", buf.String())
 	}
 
-	return nil
+	if stats := q.Stats(); stats != nil {
+		printed := time.Since(start)
+		stats.Format += printed
+		stats.Total += printed
+		opts.Stats(stats)
+	}
+
+	return execErr
 }
@@ -222,7 +222,7 @@ func TestRun_Size(t *testing.T) {
 			expected: fmt.Sprintf("%s\n", GetAttrs("foo", "size:gb")[0]),
 		},
 		{
-			query: "SELECT size FROM ./testdata WHERE name LIKE qu",
+			query: "SELECT size FROM ./testdata WHERE name LIKE qu%",
 			expected: fmt.Sprintf(
 				strings.Repeat("%s\n", 3),
 				GetAttrs("foo/quux", "size")[0],
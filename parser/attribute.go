@@ -5,19 +5,45 @@ import (
 
 	"github.com/kashav/fsql/query"
 	"github.com/kashav/fsql/tokenizer"
+	"github.com/kashav/fsql/transform"
 )
 
 var allAttributes = []string{"mode", "size", "time", "hash", "name"}
 
+// extraAttributes are valid attributes that aren't included when selecting
+// all attributes, since they're expensive to compute (or, like path and
+// container, redundant with the rest of the output).
+var extraAttributes = []string{"dupcount", "dupgroup", "content", "lines",
+	"words", "chars", "mime", "is_text", "is_binary", "width", "height",
+	"imgformat", "container", "path", "target", "device", "fstype"}
+
+// booleanAttributes are attributes which may be used as a condition on their
+// own, e.g. `WHERE is_text`.
+var booleanAttributes = []string{"is_text", "is_binary"}
+
 func isValidAttribute(attribute string) error {
 	for _, valid := range allAttributes {
 		if attribute == valid {
 			return nil
 		}
 	}
+	for _, valid := range extraAttributes {
+		if attribute == valid {
+			return nil
+		}
+	}
 	return &ErrUnknownToken{attribute}
 }
 
+func isBooleanAttribute(attribute string) bool {
+	for _, boolean := range booleanAttributes {
+		if attribute == boolean {
+			return true
+		}
+	}
+	return false
+}
+
 // parseAttrs parses the list of attributes passed to the SELECT clause.
 func (p *parser) parseAttrs(attributes *[]string, modifiers *map[string][]query.Modifier) error {
 	for {
@@ -84,8 +110,12 @@ func (p *parser) parseAttr(modifiers *[]query.Modifier) (*tokenizer.Token, error
 	}
 
 	// Parse the modifier arguments.
+	var first *tokenizer.Token
 	for {
 		if token := p.expect(tokenizer.Identifier); token != nil {
+			if first == nil {
+				first = token
+			}
 			modifier.Arguments = append(modifier.Arguments, token.Raw)
 			continue
 		}
@@ -95,6 +125,19 @@ func (p *parser) parseAttr(modifiers *[]query.Modifier) (*tokenizer.Token, error
 		}
 
 		if token := p.expect(tokenizer.CloseParen); token != nil {
+			// Patterns are compiled when the query is prepared, but are checked
+			// here so that invalid patterns are reported with their position.
+			if _, err := transform.CompileModifier(modifier.Name,
+				modifier.Arguments); err != nil {
+				if first == nil {
+					return nil, err
+				}
+				return nil, &ErrInvalidPattern{
+					Pattern:  strings.Join(modifier.Arguments, " "),
+					Position: p.tokenizer.Position(first) + 1,
+					Err:      err,
+				}
+			}
 			*modifiers = append(*modifiers, modifier)
 			return attribute, nil
 		}
@@ -1,8 +1,10 @@
 package parser
 
 import (
+	"errors"
 	"io"
 	"reflect"
+	"regexp/syntax"
 	"testing"
 
 	"github.com/kashav/fsql/query"
@@ -229,6 +231,18 @@ func TestAttributeParser_ExpectCorrectModifiers(t *testing.T) {
 			input:    "lower(name),",
 			expected: Expected{err: io.ErrUnexpectedEOF},
 		},
+		{
+			input: "matches(content, 'foo[a')",
+			expected: Expected{err: &ErrInvalidPattern{
+				Pattern:  "foo[a",
+				Position: 18,
+				Err:      &syntax.Error{Code: syntax.ErrMissingBracket, Expr: "[a"},
+			}},
+		},
+		{
+			input:    "matches(content)",
+			expected: Expected{err: errors.New("function MATCHES expects a pattern")},
+		},
 		{
 			input:    "identifier",
 			expected: Expected{err: &ErrUnknownToken{"identifier"}},
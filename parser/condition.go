@@ -1,11 +1,15 @@
 package parser
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/oleiade/lane"
 
+	"github.com/kashav/fsql/evaluate"
 	"github.com/kashav/fsql/query"
 	"github.com/kashav/fsql/tokenizer"
 )
@@ -16,7 +20,12 @@ func (p *parser) parseConditionTree() (*query.ConditionNode, error) {
 	errFailedToParse := errors.New("failed to parse conditions")
 
 	for {
-		if p.current = p.tokenizer.Next(); p.current == nil {
+		// A boolean condition (e.g. `is_text`) may leave the token following it
+		// unconsumed, otherwise read the next token.
+		if p.current == nil {
+			p.current = p.tokenizer.Next()
+		}
+		if p.current == nil {
 			break
 		}
 
@@ -63,9 +72,11 @@ func (p *parser) parseConditionTree() (*query.ConditionNode, error) {
 				Left: leftNode,
 			}
 			stack.Push(&node)
+			p.current = nil
 
 		case tokenizer.OpenParen:
 			stack.Push(nil)
+			p.current = nil
 
 		case tokenizer.CloseParen:
 			rightNode, ok := stack.Pop().(*query.ConditionNode)
@@ -79,7 +90,10 @@ func (p *parser) parseConditionTree() (*query.ConditionNode, error) {
 				rootNode.Right = rightNode
 				stack.Push(rootNode)
 			}
+			p.current = nil
 
+		default:
+			p.current = nil
 		}
 	}
 
@@ -126,8 +140,14 @@ func (p *parser) parseCondition() (*query.Condition, error) {
 	if len(modifiers) > 0 {
 		p.current = p.tokenizer.Next()
 	}
-	if p.current == nil {
-		return nil, p.currentError()
+	if p.current == nil || isConditionEnd(p.current.Type) {
+		// A boolean attribute without an operator is shorthand for `= true`.
+		if !isBooleanAttribute(cond.Attribute) {
+			return nil, p.currentError()
+		}
+		cond.Operator = tokenizer.Equals
+		cond.Value = "true"
+		return cond, nil
 	}
 	cond.Operator = p.current.Type
 	p.current = nil
@@ -170,19 +190,77 @@ func (p *parser) parseCondition() (*query.Condition, error) {
 		return nil, p.currentError()
 	}
 	cond.Value = token.Raw
+
+	if cond.Operator == tokenizer.Like || cond.Operator == tokenizer.ILike {
+		if err := p.parseEscape(cond); err != nil {
+			return nil, err
+		}
+	}
+
+	// Patterns are compiled when the query is prepared, but are checked here
+	// so that invalid patterns are reported with their position.
+	if _, err := evaluate.Compile(cond.Attribute, cond.Operator, token.Raw,
+		cond.Escape); err != nil {
+		return nil, &ErrInvalidPattern{
+			Pattern:  token.Raw,
+			Position: p.tokenizer.Position(token) + 1,
+			Err:      err,
+		}
+	}
 	return cond, nil
 }
 
+// parseEscape parses the optional `ESCAPE c` clause following the pattern of
+// a LIKE or ILIKE condition, where c is a single character.
+func (p *parser) parseEscape(cond *query.Condition) error {
+	token := p.expect(tokenizer.Identifier)
+	if token == nil {
+		return nil
+	}
+	if !strings.EqualFold(token.Raw, "ESCAPE") {
+		// Not an ESCAPE clause, so we leave it for the caller.
+		p.current = token
+		return nil
+	}
+
+	escape := p.expect(tokenizer.Identifier)
+	if escape == nil {
+		return p.currentError()
+	}
+	if runes := []rune(escape.Raw); len(runes) == 1 {
+		cond.Escape = runes[0]
+		return nil
+	}
+	return fmt.Errorf("ESCAPE expects a single character, got %s", escape.Raw)
+}
+
+// isConditionEnd returns true iff t may directly follow a condition.
+func isConditionEnd(t tokenizer.TokenType) bool {
+	return t == tokenizer.And || t == tokenizer.Or || t == tokenizer.CloseParen
+}
+
 // parseSubquery parses a subquery by recursively evaluating it's condition(s).
 // If the subquery contains references to aliases from the superquery, it's
 // Subquery attribute is set. Otherwise, we evaluate it's Subquery and set
 // it's Value to the result.
 func (p *parser) parseSubquery(condition *query.Condition) error {
-	q, err := Run(condition.Value.(string))
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q, err := (&parser{ctx: ctx, options: p.options, explain: p.explain}).
+		parse(condition.Value.(string))
 	if err != nil {
 		return err
 	}
 
+	// While explaining a query, the subquery is only described (see
+	// query.Query.Plan), so it's kept as it is.
+	if p.explain {
+		condition.Subquery = q
+		return nil
+	}
+
 	// If the subquery has aliases, we'll have to parse the subquery against
 	// each file, so we don't do anything here.
 	if len(q.SourceAliases) > 0 {
@@ -200,7 +278,12 @@ func (p *parser) parseSubquery(condition *query.Condition) error {
 		}
 	}
 
-	if err = q.Execute(workFunc); err != nil {
+	// Files skipped by the subquery were already reported, so the subquery's
+	// partial results are used as they are (unless it was interrupted, in
+	// which case the superquery is too).
+	var partial *query.ErrPartialResults
+	err = q.ExecuteContext(ctx, workFunc)
+	if err != nil && (!errors.As(err, &partial) || partial.Interrupted != nil) {
 		return err
 	}
 
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io"
 	"reflect"
+	"regexp/syntax"
 	"testing"
 
 	"github.com/kashav/fsql/query"
@@ -145,15 +146,101 @@ func TestConditionParser_ExpectCorrectCondition(t *testing.T) {
 			},
 		},
 
+		{
+			input: "is_text",
+			expected: Expected{
+				condition: &query.Condition{
+					Attribute: "is_text",
+					Operator:  tokenizer.Equals,
+					Value:     "true",
+				},
+				err: nil,
+			},
+		},
+
+		{
+			input: "NOT is_binary AND name = foo",
+			expected: Expected{
+				condition: &query.Condition{
+					Attribute: "is_binary",
+					Operator:  tokenizer.Equals,
+					Value:     "true",
+					Negate:    true,
+				},
+				err: nil,
+			},
+		},
+
+		{
+			input: "name LIKE '100!%%' ESCAPE '!'",
+			expected: Expected{
+				condition: &query.Condition{
+					Attribute: "name",
+					Operator:  tokenizer.Like,
+					Value:     "100!%%",
+					Escape:    '!',
+				},
+				err: nil,
+			},
+		},
+
+		{
+			input: "name ILIKE %.GO AND size > 10",
+			expected: Expected{
+				condition: &query.Condition{
+					Attribute: "name",
+					Operator:  tokenizer.ILike,
+					Value:     "%.GO",
+				},
+				err: nil,
+			},
+		},
+
+		{
+			input: "path GLOB 'src/**/*.go'",
+			expected: Expected{
+				condition: &query.Condition{
+					Attribute: "path",
+					Operator:  tokenizer.Glob,
+					Value:     "src/**/*.go",
+				},
+				err: nil,
+			},
+		},
+
+		{
+			input:    "name LIKE a%b ESCAPE",
+			expected: Expected{err: io.ErrUnexpectedEOF},
+		},
+
+		{
+			input:    "name LIKE a%b ESCAPE '!!'",
+			expected: Expected{err: errors.New("ESCAPE expects a single character, got !!")},
+		},
+
 		{
 			input:    "name =",
 			expected: Expected{err: io.ErrUnexpectedEOF},
 		},
 
+		{
+			input:    "name",
+			expected: Expected{err: io.ErrUnexpectedEOF},
+		},
+
 		{
 			input:    "file IS dir",
 			expected: Expected{err: &ErrUnknownToken{"file"}},
 		},
+
+		{
+			input: "name RLIKE 'foo[a'",
+			expected: Expected{err: &ErrInvalidPattern{
+				Pattern:  "foo[a",
+				Position: 12,
+				Err:      &syntax.Error{Code: syntax.ErrMissingBracket, Expr: "[a"},
+			}},
+		},
 	}
 
 	for _, c := range cases {
@@ -250,6 +337,30 @@ func TestConditionParser_ExpectCorrectConditionTree(t *testing.T) {
 			},
 		},
 
+		{
+			input: "(is_text) AND name LIKE %foo",
+			expected: Expected{
+				node: &query.ConditionNode{
+					Type: &tmpAnd,
+					Left: &query.ConditionNode{
+						Condition: &query.Condition{
+							Attribute: "is_text",
+							Operator:  tokenizer.Equals,
+							Value:     "true",
+						},
+					},
+					Right: &query.ConditionNode{
+						Condition: &query.Condition{
+							Attribute: "name",
+							Operator:  tokenizer.Like,
+							Value:     "%foo",
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+
 		{
 			input: "size <= 10 OR NOT mode IS dir",
 			expected: Expected{
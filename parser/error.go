@@ -27,6 +27,22 @@ func (e *ErrUnknownToken) Error() string {
 	return fmt.Sprintf("unknown token: %s", e.Raw)
 }
 
+// ErrInvalidPattern represents a pattern (e.g. the regular expression of an
+// RLIKE condition) which can't be compiled. Position is the 1-based position
+// of the pattern in the query.
+type ErrInvalidPattern struct {
+	Pattern  string
+	Position int
+	Err      error
+}
+
+func (e *ErrInvalidPattern) Error() string {
+	return fmt.Sprintf("invalid pattern %s at position %d: %v", e.Pattern,
+		e.Position, e.Err)
+}
+
+func (e *ErrInvalidPattern) Unwrap() error { return e.Err }
+
 // currentError returns the current error, based on the parser's current Token
 // and the previously expected TokenType (set in parser.expect).
 func (p *parser) currentError() error {
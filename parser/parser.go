@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"context"
 	"os/user"
 	"path/filepath"
 	"strings"
@@ -14,16 +15,38 @@ func Run(input string) (*query.Query, error) {
 	return (&parser{}).parse(input)
 }
 
+// RunWithOptions parses the input string and returns the parsed AST (query),
+// which (along with any subqueries) is executed with opts.
+func RunWithOptions(input string, opts *query.Options) (*query.Query, error) {
+	return RunContext(context.Background(), input, opts)
+}
+
+// RunContext is RunWithOptions, where subqueries (which are executed while
+// parsing) stop once ctx is done.
+func RunContext(ctx context.Context, input string, opts *query.Options) (*query.Query, error) {
+	return (&parser{ctx: ctx, options: opts}).parse(input)
+}
+
 type parser struct {
 	tokenizer *tokenizer.Tokenizer
 	current   *tokenizer.Token
 	expected  tokenizer.TokenType
+	options   *query.Options
+	ctx       context.Context
+
+	// explain is set while parsing `EXPLAIN SELECT ...` (and its subqueries),
+	// in which case subqueries are parsed but not run.
+	explain bool
 }
 
 // parse runs the respective parser function on each clause of the query.
 func (p *parser) parse(input string) (*query.Query, error) {
 	q := query.NewQuery()
+	q.Options = p.options
 	p.tokenizer = tokenizer.NewTokenizer(input)
+	if err := p.parseExplain(q); err != nil {
+		return nil, err
+	}
 	if err := p.parseSelectClause(q); err != nil {
 		return nil, err
 	}
@@ -36,6 +59,22 @@ func (p *parser) parse(input string) (*query.Query, error) {
 	return q, nil
 }
 
+// parseExplain parses the optional EXPLAIN keyword preceding the query. Since
+// EXPLAIN isn't a valid attribute, it's only treated as a keyword here.
+func (p *parser) parseExplain(q *query.Query) error {
+	token := p.expect(tokenizer.Identifier)
+	if token == nil {
+		return nil
+	}
+	if !strings.EqualFold(token.Raw, "EXPLAIN") {
+		p.current = token
+		return nil
+	}
+	p.explain = true
+	q.Explain = true
+	return nil
+}
+
 // parseSelectClause parses the SELECT clause of the query.
 func (p *parser) parseSelectClause(q *query.Query) error {
 	// Determine if we should show all attributes. This is only true when
@@ -81,7 +120,7 @@ func (p *parser) parseFromClause(q *query.Query) error {
 		return nil
 	}
 
-	if err := p.parseSourceList(&q.Sources, &q.SourceAliases); err != nil {
+	if err := p.parseSourceList(&q.Sources, &q.SourceAliases, &q.SourceOptions); err != nil {
 		return err
 	}
 
@@ -92,10 +131,14 @@ func (p *parser) parseFromClause(q *query.Query) error {
 	if err != nil {
 		return err
 	}
-	for _, sourceType := range []string{"include", "exclude"} {
+	for _, sourceType := range []string{"include", "exclude", "exclude-from"} {
 		for i, src := range q.Sources[sourceType] {
 			if strings.Contains(src, "~") {
 				q.Sources[sourceType][i] = filepath.Join(u.HomeDir, src[1:])
+				if opts, ok := q.SourceOptions[src]; ok {
+					delete(q.SourceOptions, src)
+					q.SourceOptions[q.Sources[sourceType][i]] = opts
+				}
 			}
 		}
 	}
@@ -363,6 +363,7 @@ func TestParser_SelectAllVariations(t *testing.T) {
 			},
 		},
 		SourceAliases: map[string]string{},
+		SourceOptions: map[string]*query.SourceOptions{},
 		Modifiers:     map[string][]query.Modifier{},
 	}
 
@@ -414,11 +415,42 @@ func TestParser_Run(t *testing.T) {
 						},
 					},
 					SourceAliases: map[string]string{},
+					SourceOptions: map[string]*query.SourceOptions{},
 					Modifiers:     map[string][]query.Modifier{},
 				},
 				err: nil,
 			},
 		},
+
+		{
+			input: "explain SELECT name FROM . WHERE name LIKE foo",
+			expected: Expected{
+				q: &query.Query{
+					Attributes: []string{"name"},
+					Sources: map[string][]string{
+						"include": {"."},
+						"exclude": {},
+					},
+					ConditionTree: &query.ConditionNode{
+						Condition: &query.Condition{
+							Attribute: "name",
+							Operator:  tokenizer.Like,
+							Value:     "foo",
+						},
+					},
+					SourceAliases: map[string]string{},
+					SourceOptions: map[string]*query.SourceOptions{},
+					Modifiers:     map[string][]query.Modifier{"name": {}},
+					Explain:       true,
+				},
+				err: nil,
+			},
+		},
+
+		{
+			input:    "EXPLAIN",
+			expected: Expected{err: io.ErrUnexpectedEOF},
+		},
 	}
 
 	for _, c := range cases {
@@ -435,3 +467,21 @@ func TestParser_Run(t *testing.T) {
 		}
 	}
 }
+
+func TestParser_ExplainSubquery(t *testing.T) {
+	// The subquery's source doesn't exist, so running it would fail.
+	input := "EXPLAIN SELECT name FROM . WHERE name IN (SELECT name FROM ./missing WHERE size > 1)"
+	q, err := Run(input)
+	if err != nil {
+		t.Fatalf("\nExpected no error\n     Got %v", err)
+	}
+
+	c := q.ConditionTree.Condition
+	if !c.IsSubquery || c.Subquery == nil {
+		t.Fatalf("\nExpected unevaluated subquery\n     Got %v", c)
+	}
+	expected := []string{"missing"}
+	if actual := c.Subquery.Sources["include"]; !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("\nExpected %v\n     Got %v", expected, actual)
+	}
+}
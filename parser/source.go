@@ -3,35 +3,77 @@ package parser
 import (
 	"fmt"
 	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/kashav/fsql/query"
 	"github.com/kashav/fsql/tokenizer"
 )
 
 // parseSourceList parses the list of directories passed to the FROM clause. If
 // a source is followed by the AS keyword, the following word is registered as
-// an alias.
+// an alias. A source wrapped in ARCHIVE(...) is read as an archive, and
+// options may follow each included source (see parseSourceOptions).
+//
+// Excluded sources may be glob/.gitignore-style patterns (e.g. `-**/build/` or
+// `-!keep.log`), and `-@file` loads exclusion patterns from a file.
 func (p *parser) parseSourceList(sources *map[string][]string,
-	aliases *map[string]string) error {
+	aliases *map[string]string, options *map[string]*query.SourceOptions) error {
 	for {
 		// If the next token is a hypen, exclude this directory.
 		sourceType := "include"
+		negate := false
 		if token := p.expect(tokenizer.Hyphen); token != nil {
 			sourceType = "exclude"
+			negate = p.expect(tokenizer.ExclamationMark) != nil
 		}
 
 		source := p.expect(tokenizer.Identifier)
 		if source == nil {
 			return p.currentError()
 		}
-		source.Raw = filepath.Clean(source.Raw)
+
+		if negate {
+			source.Raw = "!" + source.Raw
+		} else if sourceType == "exclude" && strings.HasPrefix(source.Raw, "@") &&
+			len(source.Raw) > 1 {
+			// Load exclusion patterns from a file.
+			sourceType = "exclude-from"
+			source.Raw = source.Raw[1:]
+		}
+
+		opts := &query.SourceOptions{}
+		if strings.ToUpper(source.Raw) == "ARCHIVE" && p.expect(tokenizer.OpenParen) != nil {
+			if source = p.expect(tokenizer.Identifier); source == nil {
+				return p.currentError()
+			}
+			if p.expect(tokenizer.CloseParen) == nil {
+				return p.currentError()
+			}
+			opts.Archive = true
+		}
+		if sourceType == "include" {
+			if err := p.parseSourceOptions(opts); err != nil {
+				return err
+			}
+		}
+
+		// Patterns aren't cleaned, since a trailing slash restricts a pattern to
+		// directories.
+		if sourceType == "include" || !query.IsPattern(source.Raw) {
+			source.Raw = filepath.Clean(source.Raw)
+		}
 		(*sources)[sourceType] = append((*sources)[sourceType], source.Raw)
+		if *opts != (query.SourceOptions{}) {
+			(*options)[source.Raw] = opts
+		}
 
 		if token := p.expect(tokenizer.As); token != nil {
 			alias := p.expect(tokenizer.Identifier)
 			if alias == nil {
 				return p.currentError()
 			}
-			if sourceType == "exclude" {
+			if sourceType != "include" {
 				return fmt.Errorf("cannot alias excluded directory %s", source.Raw)
 			}
 			(*aliases)[alias.Raw] = source.Raw
@@ -43,3 +85,63 @@ func (p *parser) parseSourceList(sources *map[string][]string,
 	}
 	return nil
 }
+
+// parseSourceOptions parses the options following a source: `USING GITIGNORE`,
+// `FOLLOW LINKS`, `ONE FILESYSTEM`, `MAXDEPTH n` and `MINDEPTH n`.
+func (p *parser) parseSourceOptions(opts *query.SourceOptions) error {
+	for {
+		token := p.expect(tokenizer.Identifier)
+		if token == nil {
+			return nil
+		}
+
+		var err error
+		switch keyword := strings.ToUpper(token.Raw); keyword {
+		case "USING":
+			opts.GitIgnore, err = true, p.expectKeyword(keyword, "GITIGNORE")
+		case "FOLLOW":
+			opts.FollowLinks, err = true, p.expectKeyword(keyword, "LINKS")
+		case "ONE":
+			opts.OneFilesystem, err = true, p.expectKeyword(keyword, "FILESYSTEM")
+		case "MAXDEPTH":
+			opts.MaxDepth, err = p.parseDepth(keyword)
+		case "MINDEPTH":
+			opts.MinDepth, err = p.parseDepth(keyword)
+		default:
+			// Not an option, so we leave it for the caller.
+			p.current = token
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// expectKeyword expects the second word of the two-word source option which
+// starts with keyword (e.g. the LINKS in `FOLLOW LINKS`).
+func (p *parser) expectKeyword(keyword, second string) error {
+	token := p.expect(tokenizer.Identifier)
+	if token == nil {
+		return p.currentError()
+	}
+	if strings.ToUpper(token.Raw) != second {
+		return fmt.Errorf("unknown source option %s %s", keyword, token.Raw)
+	}
+	return nil
+}
+
+// parseDepth parses the depth following the MAXDEPTH or MINDEPTH keyword,
+// which must be a positive integer.
+func (p *parser) parseDepth(keyword string) (int, error) {
+	token := p.expect(tokenizer.Identifier)
+	if token == nil {
+		return 0, p.currentError()
+	}
+	n, err := strconv.Atoi(token.Raw)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("expected positive integer after %s, got %s",
+			keyword, token.Raw)
+	}
+	return n, nil
+}
@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/kashav/fsql/query"
 	"github.com/kashav/fsql/tokenizer"
 )
 
@@ -55,6 +56,27 @@ func TestSourceParser_ExpectCorrectSources(t *testing.T) {
 				err: nil,
 			},
 		},
+		{
+			input: "., -*.log, -!keep.log, -**/build/, -./vendor/, -@.fsqlignore",
+			expected: Expected{
+				sources: map[string][]string{
+					"include":      {"."},
+					"exclude":      {"*.log", "!keep.log", "**/build/", "vendor"},
+					"exclude-from": {".fsqlignore"},
+				},
+				err: nil,
+			},
+		},
+		{
+			input: "./src/{cmd,lib}/**/*.[ch], -*.[oa]",
+			expected: Expected{
+				sources: map[string][]string{
+					"include": {"src/{cmd,lib}/**/*.[ch]"},
+					"exclude": {"*.[oa]"},
+				},
+				err: nil,
+			},
+		},
 
 		{input: "", expected: Expected{err: io.ErrUnexpectedEOF}},
 		{input: "foo,", expected: Expected{err: io.ErrUnexpectedEOF}},
@@ -63,9 +85,10 @@ func TestSourceParser_ExpectCorrectSources(t *testing.T) {
 	for _, c := range cases {
 		sources := make(map[string][]string, 0)
 		aliases := make(map[string]string, 0)
+		options := make(map[string]*query.SourceOptions, 0)
 
 		p := &parser{tokenizer: tokenizer.NewTokenizer(c.input)}
-		err := p.parseSourceList(&sources, &aliases)
+		err := p.parseSourceList(&sources, &aliases, &options)
 
 		if c.expected.err == nil {
 			if err != nil {
@@ -131,9 +154,10 @@ func TestSourceParser_ExpectCorrectAliases(t *testing.T) {
 	for _, c := range cases {
 		sources := make(map[string][]string, 0)
 		aliases := make(map[string]string, 0)
+		options := make(map[string]*query.SourceOptions, 0)
 
 		p := &parser{tokenizer: tokenizer.NewTokenizer(c.input)}
-		err := p.parseSourceList(&sources, &aliases)
+		err := p.parseSourceList(&sources, &aliases, &options)
 
 		if c.expected.err == nil {
 			if err != nil {
@@ -147,3 +171,123 @@ func TestSourceParser_ExpectCorrectAliases(t *testing.T) {
 		}
 	}
 }
+
+func TestSourceParser_ExpectCorrectOptions(t *testing.T) {
+	type Expected struct {
+		sources map[string][]string
+		options map[string]*query.SourceOptions
+		err     error
+	}
+
+	type Case struct {
+		input    string
+		expected Expected
+	}
+
+	cases := []Case{
+		{
+			input: "release.zip",
+			expected: Expected{
+				sources: map[string][]string{"include": {"release.zip"}},
+				options: map[string]*query.SourceOptions{},
+				err:     nil,
+			},
+		},
+		{
+			input: "ARCHIVE(./foo.bin), .",
+			expected: Expected{
+				sources: map[string][]string{"include": {"foo.bin", "."}},
+				options: map[string]*query.SourceOptions{
+					"foo.bin": {Archive: true},
+				},
+				err: nil,
+			},
+		},
+		{
+			input: "archive(foo.bin) AS foo",
+			expected: Expected{
+				sources: map[string][]string{"include": {"foo.bin"}},
+				options: map[string]*query.SourceOptions{
+					"foo.bin": {Archive: true},
+				},
+				err: nil,
+			},
+		},
+		{
+			input: "ARCHIVE",
+			expected: Expected{
+				sources: map[string][]string{"include": {"ARCHIVE"}},
+				options: map[string]*query.SourceOptions{},
+				err:     nil,
+			},
+		},
+		{
+			input: ". USING GITIGNORE AS cwd, ARCHIVE(a.bin) using gitignore, b",
+			expected: Expected{
+				sources: map[string][]string{"include": {".", "a.bin", "b"}},
+				options: map[string]*query.SourceOptions{
+					".":     {GitIgnore: true},
+					"a.bin": {Archive: true, GitIgnore: true},
+				},
+				err: nil,
+			},
+		},
+		{
+			input: "~ MAXDEPTH 1, src mindepth 2 MAXDEPTH 3 USING GITIGNORE FOLLOW LINKS, / one filesystem",
+			expected: Expected{
+				sources: map[string][]string{"include": {"~", "src", "/"}},
+				options: map[string]*query.SourceOptions{
+					"~":   {MaxDepth: 1},
+					"src": {MinDepth: 2, MaxDepth: 3, GitIgnore: true, FollowLinks: true},
+					"/":   {OneFilesystem: true},
+				},
+				err: nil,
+			},
+		},
+
+		{input: "ARCHIVE(", expected: Expected{err: io.ErrUnexpectedEOF}},
+		{input: "ARCHIVE(foo.bin", expected: Expected{err: io.ErrUnexpectedEOF}},
+		{input: ". USING", expected: Expected{err: io.ErrUnexpectedEOF}},
+		{input: ". MAXDEPTH", expected: Expected{err: io.ErrUnexpectedEOF}},
+		{
+			input:    ". MAXDEPTH 0",
+			expected: Expected{err: errors.New("expected positive integer after MAXDEPTH, got 0")},
+		},
+		{
+			input:    ". mindepth x",
+			expected: Expected{err: errors.New("expected positive integer after MINDEPTH, got x")},
+		},
+		{input: ". ONE", expected: Expected{err: io.ErrUnexpectedEOF}},
+		{
+			input:    ". FOLLOW ME",
+			expected: Expected{err: errors.New("unknown source option FOLLOW ME")},
+		},
+		{
+			input:    ". USING FOO",
+			expected: Expected{err: errors.New("unknown source option USING FOO")},
+		},
+	}
+
+	for _, c := range cases {
+		sources := make(map[string][]string, 0)
+		aliases := make(map[string]string, 0)
+		options := make(map[string]*query.SourceOptions, 0)
+
+		p := &parser{tokenizer: tokenizer.NewTokenizer(c.input)}
+		err := p.parseSourceList(&sources, &aliases, &options)
+
+		if c.expected.err == nil {
+			if err != nil {
+				t.Fatalf("\nExpected no error\n     Got %v", err)
+			}
+			if !reflect.DeepEqual(c.expected.sources, sources) {
+				t.Fatalf("\nExpected %v\n     Got %v", c.expected.sources, sources)
+			}
+			if !reflect.DeepEqual(c.expected.options, options) {
+				t.Fatalf("\nExpected %v\n     Got %v", c.expected.options, options)
+			}
+		} else if !reflect.DeepEqual(c.expected.err, err) {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected.err, err)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package query
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// archiveFormat is a supported archive format.
+type archiveFormat int
+
+const (
+	notArchive archiveFormat = iota
+	zipArchive
+	tarArchive
+	tarGzArchive
+)
+
+// formatByExtension returns the archive format of the file located at path,
+// based on its extension.
+func formatByExtension(path string) archiveFormat {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return zipArchive
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return tarGzArchive
+	case strings.HasSuffix(lower, ".tar"):
+		return tarArchive
+	}
+	return notArchive
+}
+
+// sniffFormat returns the archive format of the file name in fsys, based on
+// its leading bytes.
+func sniffFormat(fsys fs.FS, name string) (archiveFormat, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return notArchive, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return notArchive, err
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")),
+		bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return zipArchive, nil
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return tarGzArchive, nil
+	case len(head) >= 262 && bytes.Equal(head[257:262], []byte("ustar")):
+		return tarArchive, nil
+	}
+	return notArchive, nil
+}
+
+// archive is an open archive, which is walked as a virtual directory tree.
+type archive struct {
+	path   string
+	fsys   fs.FS
+	closer io.Closer
+}
+
+// openArchive opens the archive name in fsys, which is shown as path.
+func openArchive(fsys fs.FS, name, path string, format archiveFormat) (*archive, error) {
+	switch format {
+	case zipArchive:
+		r, closer, err := openZip(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		return &archive{path: path, fsys: r, closer: closer}, nil
+	case tarArchive, tarGzArchive:
+		t, err := newTarFS(fsys, name, format == tarGzArchive)
+		if err != nil {
+			return nil, err
+		}
+		return &archive{path: path, fsys: t, closer: t}, nil
+	}
+	return nil, fmt.Errorf("%s: unsupported archive format", path)
+}
+
+// openZip opens the zip archive name in fsys. The archive is read in place if
+// the file supports random access, and is otherwise read into memory.
+func openZip(fsys fs.FS, name string) (*zip.Reader, io.Closer, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		b, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		ra, f = bytes.NewReader(b), nil
+	}
+
+	r, err := zip.NewReader(ra, info.Size())
+	// Members with insecure names (e.g. absolute paths) are excluded from the
+	// archive's fs.FS, so the rest of the archive is still usable.
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		if f != nil {
+			f.Close()
+		}
+		return nil, nil, err
+	}
+	return r, f, nil
+}
+
+// close closes the archive.
+func (a *archive) close() error {
+	if a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
+
+// findArchive returns the archive that src points into (if any), along with
+// the path of src inside the archive. Archives are recognized by extension,
+// unless opts.Archive is set, in which case src itself must be an archive (of
+// any supported format).
+//
+// Archives are opened once per execution and closed by closeArchives.
+func (q *Query) findArchive(src string, opts SourceOptions) (*archive, string, error) {
+	if opts.Archive {
+		fsys, name := q.locate(src)
+		format, err := sniffFormat(fsys, name)
+		if err != nil {
+			return nil, "", err
+		}
+		a, err := q.openArchive(src, format)
+		return a, ".", err
+	}
+
+	for path := src; ; path = filepath.Dir(path) {
+		if format := formatByExtension(path); format != notArchive {
+			fsys, name := q.locate(path)
+			if info, err := fs.Stat(fsys, name); err == nil && info.Mode().IsRegular() {
+				rel, err := filepath.Rel(path, src)
+				if err != nil {
+					return nil, "", err
+				}
+				a, err := q.openArchive(path, format)
+				return a, filepath.ToSlash(rel), err
+			}
+		}
+		if filepath.Dir(path) == path {
+			return nil, "", nil
+		}
+	}
+}
+
+// openArchive opens the archive located at path, or returns it if it's
+// already open.
+func (q *Query) openArchive(path string, format archiveFormat) (*archive, error) {
+	if a, ok := q.archives[path]; ok {
+		return a, nil
+	}
+
+	fsys, name := q.locate(path)
+	a, err := openArchive(fsys, name, path, format)
+	if err != nil {
+		return nil, err
+	}
+	if q.archives == nil {
+		q.archives = make(map[string]*archive)
+	}
+	q.archives[path] = a
+	return a, nil
+}
+
+// closeArchives closes each archive opened while executing this query.
+func (q *Query) closeArchives() {
+	for _, a := range q.archives {
+		a.close()
+	}
+	q.archives = nil
+}
@@ -0,0 +1,246 @@
+package query
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/kashav/fsql/transform"
+)
+
+// archiveMembers are the members written to each test archive.
+var archiveMembers = []struct{ name, content string }{
+	{"README", "readme"},
+	{"docs/", ""},
+	{"docs/a.txt", "foo"},
+	{"src/main.go", "package main"},
+}
+
+var archiveTime = time.Date(2017, 5, 28, 16, 37, 18, 0, time.UTC)
+
+func writeZip(t *testing.T, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("\nExpected no error\n     Got %v", err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for _, m := range archiveMembers {
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: m.name, Modified: archiveTime})
+		if err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+		io.WriteString(fw, m.content)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("\nExpected no error\n     Got %v", err)
+	}
+}
+
+func writeTar(t *testing.T, path string, gzipped bool) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("\nExpected no error\n     Got %v", err)
+	}
+	defer f.Close()
+
+	var out io.Writer = f
+	if gzipped {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		out = gz
+	}
+
+	w := tar.NewWriter(out)
+	for _, m := range archiveMembers {
+		header := &tar.Header{
+			Name:    m.name,
+			Mode:    0644,
+			Size:    int64(len(m.content)),
+			ModTime: archiveTime,
+		}
+		if m.name[len(m.name)-1] == '/' {
+			header.Typeflag, header.Mode = tar.TypeDir, 0755
+		}
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+		io.WriteString(w, m.content)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("\nExpected no error\n     Got %v", err)
+	}
+}
+
+func TestArchive_TarFS(t *testing.T) {
+	for _, gzipped := range []bool{false, true} {
+		dir := t.TempDir()
+		writeTar(t, filepath.Join(dir, "test.tar"), gzipped)
+
+		fsys, err := newTarFS(os.DirFS(dir), "test.tar", gzipped)
+		if err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+		if err := fstest.TestFS(fsys, "README", "docs/a.txt", "src/main.go"); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+	}
+}
+
+// openCountingFS is a filesystem which counts the number of times each file is
+// opened.
+type openCountingFS struct {
+	fs.FS
+	opened map[string]int
+}
+
+func (f *openCountingFS) Open(name string) (fs.File, error) {
+	f.opened[name]++
+	return f.FS.Open(name)
+}
+
+func TestArchive_TarFSSinglePass(t *testing.T) {
+	for _, gzipped := range []bool{false, true} {
+		dir := t.TempDir()
+		writeTar(t, filepath.Join(dir, "test.tar"), gzipped)
+
+		fsys := &openCountingFS{FS: os.DirFS(dir), opened: make(map[string]int)}
+		tfs, err := newTarFS(fsys, "test.tar", gzipped)
+		if err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+
+		// Members are read in the reverse of their order in the archive, as
+		// well as a second time, but the archive is only read once (after
+		// it's indexed).
+		for i := 0; i < 2; i++ {
+			for j := len(archiveMembers) - 1; j >= 0; j-- {
+				m := archiveMembers[j]
+				if strings.HasSuffix(m.name, "/") {
+					continue
+				}
+				b, err := fs.ReadFile(tfs, m.name)
+				if err != nil {
+					t.Fatalf("\nExpected no error\n     Got %v", err)
+				}
+				if string(b) != m.content {
+					t.Fatalf("\nExpected %v\n     Got %v", m.content, string(b))
+				}
+			}
+		}
+		if fsys.opened["test.tar"] != 2 {
+			t.Fatalf("\nExpected %v\n     Got %v", 2, fsys.opened["test.tar"])
+		}
+		if err := tfs.Close(); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+	}
+}
+
+func TestArchive_Execute(t *testing.T) {
+	type Result struct {
+		name      string
+		size      int64
+		hash      interface{}
+		container interface{}
+	}
+
+	type Case struct {
+		archive string
+		write   func(t *testing.T, path string)
+		source  string
+		options *SourceOptions
+	}
+
+	dir := t.TempDir()
+	cases := []Case{
+		{archive: "release.zip", write: writeZip},
+		{archive: "release.tar", write: func(t *testing.T, path string) { writeTar(t, path, false) }},
+		{archive: "release.tgz", write: func(t *testing.T, path string) { writeTar(t, path, true) }},
+		{
+			archive: "release.bin",
+			write:   func(t *testing.T, path string) { writeTar(t, path, true) },
+			options: &SourceOptions{Archive: true},
+		},
+		{archive: "docs.zip", write: writeZip, source: "docs"},
+	}
+
+	for _, c := range cases {
+		archive := filepath.Join(dir, c.archive)
+		c.write(t, archive)
+
+		dirHash := "----------------------------------------"
+		expected := map[string]Result{
+			filepath.Join(archive, "docs"): {"docs", 0, dirHash, archive},
+			filepath.Join(archive, "docs/a.txt"): {"a.txt", 3,
+				"0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33", archive},
+		}
+		// Only members of docs/ are walked when the source points into it.
+		if c.source == "" {
+			expected[filepath.Join(archive, "README")] = Result{"README", 6,
+				"f78a71af8bbf8cc2f6f313549d4da14bd3771359", archive}
+			expected[filepath.Join(archive, "src")] = Result{"src", 0, dirHash, archive}
+			expected[filepath.Join(archive, "src/main.go")] = Result{"main.go", 12,
+				"04eb6f1bdaf51ae48ed5cf0153fad8593467b778", archive}
+		}
+
+		src := filepath.Join(archive, c.source)
+		q := NewQuery()
+		q.Sources["include"] = []string{src}
+		if c.options != nil {
+			q.SourceOptions[src] = c.options
+		}
+
+		actual := map[string]Result{}
+		if err := q.Execute(
+			func(path string, info os.FileInfo, _ map[string]interface{}) {
+				hash, err := transform.HashFile(info, path, "SHA1", -1)
+				if err != nil {
+					t.Fatalf("\nExpected no error\n     Got %v", err)
+				}
+				if !info.ModTime().Equal(archiveTime) && !info.IsDir() {
+					t.Fatalf("\nExpected %v\n     Got %v", archiveTime, info.ModTime())
+				}
+				actual[path] = Result{
+					name:      info.Name(),
+					size:      info.Size(),
+					hash:      hash,
+					container: transform.Container(info),
+				}
+			},
+		); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+
+		if !reflect.DeepEqual(expected, actual) {
+			t.Fatalf("%s\nExpected %v\n     Got %v", c.archive, expected, actual)
+		}
+		if q.archives != nil {
+			t.Fatalf("\nExpected archives to be closed\n     Got %v", q.archives)
+		}
+	}
+}
+
+func TestArchive_ExecuteNotArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "foo.bin")
+	if err := os.WriteFile(path, []byte("foo"), 0644); err != nil {
+		t.Fatalf("\nExpected no error\n     Got %v", err)
+	}
+
+	q := NewQuery()
+	q.Sources["include"] = []string{path}
+	q.SourceOptions[path] = &SourceOptions{Archive: true}
+	if err := q.Execute(func(string, os.FileInfo, map[string]interface{}) {}); err == nil {
+		t.Fatalf("\nExpected error\n     Got nil")
+	}
+}
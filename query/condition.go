@@ -1,9 +1,12 @@
 package query
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"regexp"
 
 	"github.com/kashav/fsql/evaluate"
 	"github.com/kashav/fsql/tokenizer"
@@ -27,10 +30,54 @@ func (root *ConditionNode) String() string {
 		root.Condition)
 }
 
+// prepare applies the modifiers of each condition in the tree rooted at root
+// ahead of time, so that the tree may be evaluated concurrently. Paths in
+// condition values are read from fsys (or the OS filesystem, if nil).
+func (root *ConditionNode) prepare(fsys fs.FS) error {
+	if root == nil {
+		return nil
+	}
+
+	if root.Condition != nil {
+		if root.Condition.IsSubquery || root.Condition.Parsed {
+			return nil
+		}
+		return root.Condition.applyModifiers(fsys)
+	}
+
+	if err := root.Left.prepare(fsys); err != nil {
+		return err
+	}
+	return root.Right.prepare(fsys)
+}
+
+// hasAttribute checks if any condition in the tree rooted at root tests any of
+// the provided attributes.
+func (root *ConditionNode) hasAttribute(attributes ...string) bool {
+	if root == nil {
+		return false
+	}
+
+	if root.Condition != nil {
+		for _, attribute := range attributes {
+			if root.Condition.Attribute == attribute {
+				return true
+			}
+		}
+		return false
+	}
+
+	return root.Left.hasAttribute(attributes...) ||
+		root.Right.hasAttribute(attributes...)
+}
+
 // evaluateTree runs pre-order traversal on the ConditionNode tree rooted at
 // root and evaluates each conditional along the path with the provided compare
-// method.
-func (root *ConditionNode) evaluateTree(path string, info os.FileInfo) (bool, error) {
+// method. Values in computed are passed along to each condition, and reading
+// the file stops once ctx is done. The result of each condition is recorded
+// in stats, if it's non-nil.
+func (root *ConditionNode) evaluateTree(ctx context.Context, path string,
+	info os.FileInfo, computed map[string]interface{}, stats *statistics) (bool, error) {
 	if root == nil {
 		return true, nil
 	}
@@ -43,30 +90,36 @@ func (root *ConditionNode) evaluateTree(path string, info os.FileInfo) (bool, er
 		}
 
 		if !root.Condition.Parsed {
-			if err := root.Condition.applyModifiers(); err != nil {
+			// Trees are prepared before execution, so this is only reached when
+			// evaluating a tree directly (e.g. in tests).
+			if err := root.Condition.applyModifiers(nil); err != nil {
 				return false, err
 			}
 		}
 
-		return root.Condition.evaluate(path, info)
+		ok, err := root.Condition.evaluate(ctx, path, info, computed)
+		if err == nil {
+			stats.record(root.Condition, ok)
+		}
+		return ok, err
 	}
 
 	if *root.Type == tokenizer.And {
-		if ok, err := root.Left.evaluateTree(path, info); err != nil {
+		if ok, err := root.Left.evaluateTree(ctx, path, info, computed, stats); err != nil {
 			return false, err
 		} else if !ok {
 			return false, nil
 		}
-		return root.Right.evaluateTree(path, info)
+		return root.Right.evaluateTree(ctx, path, info, computed, stats)
 	}
 
 	if *root.Type == tokenizer.Or {
-		if ok, err := root.Left.evaluateTree(path, info); err != nil {
+		if ok, err := root.Left.evaluateTree(ctx, path, info, computed, stats); err != nil {
 			return false, nil
 		} else if ok {
 			return true, nil
 		}
-		return root.Right.evaluateTree(path, info)
+		return root.Right.evaluateTree(ctx, path, info, computed, stats)
 	}
 
 	return false, nil
@@ -82,12 +135,22 @@ type Condition struct {
 	Value    interface{}
 	Negate   bool
 
+	// Escape is the escape character of a LIKE or ILIKE pattern (i.e. its
+	// ESCAPE clause), or 0 if it doesn't have one.
+	Escape rune
+
+	// Pattern is the compiled pattern of Value, for operators which use one
+	// (e.g. RLIKE). It's compiled once the modifiers have been applied.
+	Pattern *regexp.Regexp
+
 	Subquery   *Query
 	IsSubquery bool
 }
 
-// ApplyModifiers applies each modifier to the value of this Condition.
-func (c *Condition) applyModifiers() error {
+// ApplyModifiers applies each modifier to the value of this Condition and
+// compiles the resultant pattern, if any. Paths in the value are read from
+// fsys (or the OS filesystem, if nil).
+func (c *Condition) applyModifiers(fsys fs.FS) error {
 	value := c.Value
 
 	for _, m := range c.AttributeModifiers {
@@ -97,19 +160,34 @@ func (c *Condition) applyModifiers() error {
 			Value:     value,
 			Name:      m.Name,
 			Args:      m.Arguments,
+			FS:        fsys,
 		})
 		if err != nil {
 			return err
 		}
 	}
 
+	pattern, err := evaluate.Compile(c.Attribute, c.Operator, value, c.Escape)
+	if err != nil {
+		return err
+	}
+	c.Pattern = pattern
+
 	c.Value = value
 	c.Parsed = true
 	return nil
 }
 
+// correlated reports whether this condition's subquery uses source aliases, in
+// which case it has to be run against each file (rather than once, with its
+// results becoming the condition's value).
+func (c *Condition) correlated() bool {
+	return c.Subquery != nil && len(c.Subquery.SourceAliases) > 0
+}
+
 // evaluate runs the respective evaluate function for this Condition.
-func (c *Condition) evaluate(path string, file os.FileInfo) (bool, error) {
+func (c *Condition) evaluate(ctx context.Context, path string, file os.FileInfo,
+	computed map[string]interface{}) (bool, error) {
 	// FIXME: This is a bit of a hack. We can't pass c.AttributeModifiers, since
 	// that'll cause a import cycle, so we have to recreate the attribute
 	// modifiers slice using a separate type defined in evaluate.
@@ -125,9 +203,16 @@ func (c *Condition) evaluate(path string, file os.FileInfo) (bool, error) {
 		Modifiers: modifiers,
 		Operator:  c.Operator,
 		Value:     c.Value,
+		Pattern:   c.Pattern,
+		Escape:    c.Escape,
+		Computed:  computed,
+		Context:   ctx,
 	}
 	result, err := evaluate.Evaluate(o)
-	if err != nil {
+	if err == evaluate.ErrUnknown {
+		// A comparison with NULL doesn't match, whether it's negated or not.
+		return false, nil
+	} else if err != nil {
 		return false, err
 	}
 	if c.Negate {
@@ -0,0 +1,193 @@
+package query
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/kashav/fsql/transform"
+)
+
+// duplicateAttributes are the attributes which require finding duplicate
+// files before the query is evaluated.
+var duplicateAttributes = []string{"dupcount", "dupgroup"}
+
+// duplicatePrefixSize is the number of leading bytes hashed when narrowing
+// down candidate duplicates, before computing the full hash.
+const duplicatePrefixSize = 4 << 10
+
+// duplicate holds the duplicate group of a single file.
+type duplicate struct {
+	// count is the number of files with identical content (including the file
+	// itself), or 0 if the file isn't a regular file.
+	count int64
+	// group is a 1-based index shared by each file in the group, or 0 if the
+	// file has no duplicates. Groups are numbered in walk order.
+	group int64
+}
+
+// candidate is a regular file which may have duplicates.
+type candidate struct {
+	path string
+	info os.FileInfo
+	hash string
+
+	// failed is true if the file couldn't be hashed, in which case it has no
+	// duplicates.
+	failed bool
+}
+
+// findDuplicates finds every set of regular files with identical content in
+// this query's sources and stores the result in q.duplicates.
+//
+// Files are compared in stages, so that most files are never read: first by
+// size, then by the hash of their first few kilobytes, and finally by their
+// full hash.
+func (q *Query) findDuplicates(ctx context.Context) error {
+	// Each file is visited again by the query's own walk, so it's only
+	// counted by the statistics (if any) then.
+	stats := q.stats
+	q.stats = nil
+	files := make([]*candidate, 0)
+	err := q.walk(ctx, func(path string, info os.FileInfo) error {
+		if info.Mode().IsRegular() {
+			files = append(files, &candidate{path: path, info: info})
+		}
+		return nil
+	}, nil)
+	q.stats = stats
+	if err != nil {
+		return err
+	}
+
+	groups := groupCandidates([][]*candidate{files}, func(c *candidate) interface{} {
+		return c.info.Size()
+	})
+
+	if groups, err = q.groupByHash(ctx, groups, duplicatePrefixSize); err != nil {
+		return err
+	}
+	if groups, err = q.groupByHash(ctx, groups, -1); err != nil {
+		return err
+	}
+
+	q.duplicates = make(map[string]duplicate, len(files))
+	for _, file := range files {
+		q.duplicates[file.path] = duplicate{count: 1}
+	}
+
+	// Number groups by the walk order of their first file. Since groups are
+	// only ever split, the files in each group remain in walk order.
+	first := make(map[string]int, len(groups))
+	for i, group := range groups {
+		first[group[0].path] = i
+	}
+	var n int64
+	for _, file := range files {
+		i, ok := first[file.path]
+		if !ok {
+			continue
+		}
+		n++
+		for _, c := range groups[i] {
+			q.duplicates[c.path] = duplicate{count: int64(len(groups[i])), group: n}
+		}
+	}
+	return nil
+}
+
+// groupByHash splits each group by the hash of the first limit bytes of each
+// file (or the full file, if limit is negative). Files which are no larger
+// than limit were fully hashed in an earlier stage, so they're not rehashed.
+func (q *Query) groupByHash(ctx context.Context, groups [][]*candidate,
+	limit int64) ([][]*candidate, error) {
+	pending := make([]*candidate, 0)
+	for _, group := range groups {
+		for _, c := range group {
+			if limit < 0 && c.info.Size() <= duplicatePrefixSize {
+				continue
+			}
+			pending = append(pending, c)
+		}
+	}
+
+	if err := q.hashCandidates(ctx, pending, limit); err != nil {
+		return nil, err
+	}
+
+	return groupCandidates(groups, func(c *candidate) interface{} {
+		if c.failed {
+			return c
+		}
+		return c.hash
+	}), nil
+}
+
+// hashCandidates hashes each candidate with up to Options.Jobs workers, which
+// stop reading files once ctx is done.
+func (q *Query) hashCandidates(ctx context.Context, candidates []*candidate,
+	limit int64) error {
+	jobs := q.options().Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		err  error
+		next = make(chan *candidate)
+	)
+
+	wg.Add(jobs)
+	for i := 0; i < jobs; i++ {
+		go func() {
+			defer wg.Done()
+			for c := range next {
+				h, hashErr := transform.HashFileContext(ctx, c.info, c.path, "SHA1", limit)
+				if hashErr != nil {
+					hashErr = q.tolerate(hashErr)
+					c.failed = true
+				}
+				mu.Lock()
+				if hashErr != nil && err == nil {
+					err = hashErr
+				} else if !c.failed {
+					c.hash = h.(string)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, c := range candidates {
+		next <- c
+	}
+	close(next)
+	wg.Wait()
+	return err
+}
+
+// groupCandidates splits each group by the key returned by keyFunc and
+// returns every resulting group with more than one file.
+func groupCandidates(groups [][]*candidate,
+	keyFunc func(*candidate) interface{}) [][]*candidate {
+	result := make([][]*candidate, 0)
+	for _, group := range groups {
+		keys := make([]interface{}, 0)
+		byKey := make(map[interface{}][]*candidate)
+		for _, c := range group {
+			key := keyFunc(c)
+			if _, ok := byKey[key]; !ok {
+				keys = append(keys, key)
+			}
+			byKey[key] = append(byKey[key], c)
+		}
+		for _, key := range keys {
+			if len(byKey[key]) > 1 {
+				result = append(result, byKey[key])
+			}
+		}
+	}
+	return result
+}
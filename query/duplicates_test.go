@@ -0,0 +1,54 @@
+package query
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDuplicates_FindDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	large := strings.Repeat("x", 2*duplicatePrefixSize)
+	files := map[string]string{
+		"a":         "foo",
+		"b":         "bar",
+		"sub/a":     "foo",
+		"large":     large,
+		"large2":    large,
+		"largeDiff": large[:len(large)-1] + "y",
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatalf("\nExpected no error\n     Got %v", err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+	}
+
+	q := NewQuery()
+	q.Sources["include"] = []string{dir}
+	if err := q.findDuplicates(context.Background()); err != nil {
+		t.Fatalf("\nExpected no error\n     Got %v", err)
+	}
+
+	expected := map[string]duplicate{
+		"a":         {count: 2, group: 1},
+		"b":         {count: 1},
+		"sub/a":     {count: 2, group: 1},
+		"large":     {count: 2, group: 2},
+		"large2":    {count: 2, group: 2},
+		"largeDiff": {count: 1},
+	}
+	for name, d := range expected {
+		if actual := q.duplicates[filepath.Join(dir, name)]; !reflect.DeepEqual(d, actual) {
+			t.Fatalf("%s\nExpected %v\n     Got %v", name, d, actual)
+		}
+	}
+	if actual := q.duplicates[filepath.Join(dir, "sub")]; actual.count != 0 {
+		t.Fatalf("\nExpected 0\n     Got %v", actual.count)
+	}
+}
@@ -0,0 +1,139 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// ErrorPolicy determines how errors reading files (e.g. permission errors)
+// are handled while executing a query.
+type ErrorPolicy int
+
+const (
+	// FailOnError stops the query at the first error.
+	FailOnError ErrorPolicy = iota
+	// SkipOnError skips each file which can't be read.
+	SkipOnError
+	// WarnOnError skips each file which can't be read, and reports the error
+	// through Options.Warn.
+	WarnOnError
+)
+
+// ParseErrorPolicy returns the ErrorPolicy named s (`skip`, `warn` or `fail`).
+func ParseErrorPolicy(s string) (ErrorPolicy, error) {
+	switch strings.ToLower(s) {
+	case "fail":
+		return FailOnError, nil
+	case "skip":
+		return SkipOnError, nil
+	case "warn":
+		return WarnOnError, nil
+	}
+	return FailOnError, fmt.Errorf("unknown error policy %s (expected skip, warn or fail)", s)
+}
+
+// ErrPartialResults is returned by Execute when files were skipped due to
+// errors, or the query was interrupted, in which case the results of the
+// query may be incomplete.
+type ErrPartialResults struct {
+	Errors []error
+
+	// Interrupted is the error of the context which stopped the query (e.g.
+	// context.DeadlineExceeded), if any.
+	Interrupted error
+}
+
+func (e *ErrPartialResults) Error() string {
+	msg := "results are partial"
+	switch {
+	case e.Interrupted == nil:
+	case errors.Is(e.Interrupted, context.DeadlineExceeded):
+		msg += ", query timed out"
+	case errors.Is(e.Interrupted, context.Canceled):
+		msg += ", query was cancelled"
+	default:
+		msg += fmt.Sprintf(", query was interrupted: %v", e.Interrupted)
+	}
+	if len(e.Errors) == 0 {
+		return msg
+	}
+
+	counts := make(map[string]int)
+	for _, err := range e.Errors {
+		// Group errors by their cause, without the path of each file.
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		counts[err.Error()]++
+	}
+
+	causes := make([]string, 0, len(counts))
+	for cause := range counts {
+		causes = append(causes, cause)
+	}
+	sort.Slice(causes, func(i, j int) bool {
+		if counts[causes[i]] != counts[causes[j]] {
+			return counts[causes[i]] > counts[causes[j]]
+		}
+		return causes[i] < causes[j]
+	})
+	for i, cause := range causes {
+		causes[i] = fmt.Sprintf("%s (%d)", cause, counts[cause])
+	}
+
+	noun := "files"
+	if len(e.Errors) == 1 {
+		noun = "file"
+	}
+	return fmt.Sprintf("%s, skipped %d %s due to errors: %s", msg,
+		len(e.Errors), noun, strings.Join(causes, ", "))
+}
+
+// Unwrap returns the error of the context which interrupted the query, so
+// that errors.Is(err, context.Canceled) holds for an interrupted query.
+func (e *ErrPartialResults) Unwrap() error { return e.Interrupted }
+
+// tolerate returns nil if err may be skipped under this query's error policy,
+// in which case err is recorded (and reported, if the policy is to warn).
+// Otherwise, err is returned. Only errors accessing files may be skipped.
+func (q *Query) tolerate(err error) error {
+	var pathErr *fs.PathError
+	policy := q.options().OnError
+	if err == nil || policy == FailOnError || !errors.As(err, &pathErr) {
+		return err
+	}
+
+	q.skippedMu.Lock()
+	defer q.skippedMu.Unlock()
+	q.skipped = append(q.skipped, err)
+	if policy == WarnOnError {
+		q.options().warn(err)
+	}
+	return nil
+}
+
+// partialResults returns the error of an execution of this query which
+// stopped with err. If the execution was interrupted by ctx, or otherwise
+// succeeded but skipped files, an ErrPartialResults holding each skipped error
+// is returned. Any other error is returned as it is.
+func (q *Query) partialResults(ctx context.Context, err error) error {
+	interrupted := ctx.Err()
+	if err != nil && (interrupted == nil || !errors.Is(err, interrupted)) {
+		return err
+	}
+	if err == nil {
+		interrupted = nil
+	}
+
+	q.skippedMu.Lock()
+	defer q.skippedMu.Unlock()
+	if len(q.skipped) == 0 && interrupted == nil {
+		return nil
+	}
+	return &ErrPartialResults{Errors: q.skipped, Interrupted: interrupted}
+}
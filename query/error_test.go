@@ -0,0 +1,183 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kashav/fsql/tokenizer"
+)
+
+// deniedFS is a filesystem in which the files in denied can't be opened.
+type deniedFS struct {
+	fstest.MapFS
+	denied map[string]bool
+}
+
+func (d *deniedFS) Open(name string) (fs.File, error) {
+	if d.denied[name] {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return d.MapFS.Open(name)
+}
+
+func (d *deniedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if d.denied[name] {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return d.MapFS.ReadDir(name)
+}
+
+func TestErrorPolicy_Parse(t *testing.T) {
+	type Case struct {
+		input    string
+		expected ErrorPolicy
+		err      bool
+	}
+
+	cases := []Case{
+		{input: "fail", expected: FailOnError},
+		{input: "SKIP", expected: SkipOnError},
+		{input: "warn", expected: WarnOnError},
+		{input: "ignore", err: true},
+	}
+
+	for _, c := range cases {
+		actual, err := ParseErrorPolicy(c.input)
+		if (err != nil) != c.err || actual != c.expected {
+			t.Fatalf("\nExpected %v (error: %v)\n     Got %v (%v)", c.expected, c.err, actual, err)
+		}
+	}
+}
+
+func TestErrorPolicy_Execute(t *testing.T) {
+	type Case struct {
+		policy   ErrorPolicy
+		jobs     int
+		expected []string
+		warnings int
+	}
+
+	fsys := &deniedFS{
+		MapFS: fstest.MapFS{
+			"a/1":      {Data: []byte("1")},
+			"b/2":      {Data: []byte("2")},
+			"c":        {Data: []byte("3")},
+			"secret":   {Data: []byte("4")},
+			"z/last/3": {Data: []byte("5")},
+		},
+		denied: map[string]bool{"b": true, "secret": true},
+	}
+
+	cases := []Case{
+		{policy: SkipOnError, jobs: 1, expected: []string{"a/1", "c", "z/last/3"}},
+		{policy: WarnOnError, jobs: 1, expected: []string{"a/1", "c", "z/last/3"}, warnings: 2},
+		{policy: WarnOnError, jobs: 4, expected: []string{"a/1", "c", "z/last/3"}, warnings: 2},
+	}
+
+	for _, c := range cases {
+		warnings := 0
+		q := NewQuery()
+		q.Sources["include"] = []string{"."}
+		q.ConditionTree = &ConditionNode{Condition: &Condition{
+			Attribute: "hash",
+			Operator:  tokenizer.NotEquals,
+			Value:     "",
+		}}
+		q.Options = &Options{Jobs: c.jobs, OnError: c.policy, FS: fsys,
+			Warn: func(error) { warnings++ }}
+
+		actual := make([]string, 0)
+		err := q.Execute(func(path string, info os.FileInfo, _ map[string]interface{}) {
+			if !info.IsDir() {
+				actual = append(actual, path)
+			}
+		})
+
+		var partial *ErrPartialResults
+		if !errors.As(err, &partial) || len(partial.Errors) != 2 {
+			t.Fatalf("\nExpected 2 skipped files\n     Got %v", err)
+		}
+		expected := "results are partial, skipped 2 files due to errors: permission denied (2)"
+		if err.Error() != expected {
+			t.Fatalf("\nExpected %s\n     Got %s", expected, err.Error())
+		}
+		if !reflect.DeepEqual(c.expected, actual) {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected, actual)
+		}
+		if warnings != c.warnings {
+			t.Fatalf("\nExpected %d warnings\n     Got %d", c.warnings, warnings)
+		}
+	}
+
+	q := NewQuery()
+	q.Sources["include"] = []string{"."}
+	q.Options = &Options{Jobs: 1, FS: fsys}
+	err := q.Execute(func(string, os.FileInfo, map[string]interface{}) {})
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("\nExpected permission denied\n     Got %v", err)
+	}
+}
+
+func TestErrorPolicy_Interrupted(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/1": {Data: []byte("1")},
+		"b/2": {Data: []byte("2")},
+		"c":   {Data: []byte("3")},
+	}
+
+	type Case struct {
+		jobs     int
+		expected []string
+	}
+
+	cases := []Case{
+		{jobs: 1, expected: []string{"a", "a/1"}},
+		{jobs: 4, expected: []string{"a", "a/1"}},
+	}
+
+	for _, c := range cases {
+		ctx, cancel := context.WithCancel(context.Background())
+		q := NewQuery()
+		q.Sources["include"] = []string{"."}
+		q.Options = &Options{Jobs: c.jobs, FS: fsys}
+
+		// The query is cancelled once the first file is found, so the files
+		// found by then are partial results.
+		actual := make([]string, 0)
+		err := q.ExecuteContext(ctx, func(path string, info os.FileInfo, _ map[string]interface{}) {
+			actual = append(actual, path)
+			if path == "a/1" {
+				cancel()
+			}
+		})
+		cancel()
+
+		var partial *ErrPartialResults
+		if !errors.As(err, &partial) || !errors.Is(err, context.Canceled) {
+			t.Fatalf("\nExpected partial results\n     Got %v", err)
+		}
+		expected := "results are partial, query was cancelled"
+		if err.Error() != expected {
+			t.Fatalf("\nExpected %s\n     Got %s", expected, err.Error())
+		}
+		if !reflect.DeepEqual(c.expected, actual) {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected, actual)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	q := NewQuery()
+	q.Sources["include"] = []string{"."}
+	q.Options = &Options{Jobs: 1, FS: fsys}
+	err := q.ExecuteContext(ctx, func(string, os.FileInfo, map[string]interface{}) {})
+	expected := "results are partial, query timed out"
+	if !errors.Is(err, context.DeadlineExceeded) || err.Error() != expected {
+		t.Fatalf("\nExpected %s\n     Got %v", expected, err)
+	}
+}
@@ -1,14 +1,19 @@
 package query
 
 import (
+	"bufio"
 	"fmt"
+	"io/fs"
+	"path/filepath"
 	"regexp"
 	"strings"
+
+	"github.com/kashav/fsql/transform"
 )
 
 // Excluder allows us to support different methods of excluding in the future.
 type Excluder interface {
-	shouldExclude(path string) bool
+	shouldExclude(path string, isDir bool) bool
 }
 
 // regexpExclude uses regular expressions to tell if a file/path should be
@@ -20,7 +25,7 @@ type regexpExclude struct {
 
 // ShouldExclude will return a boolean denoting whether or not the path should
 // be excluded based on the given slice of exclusions.
-func (r *regexpExclude) shouldExclude(path string) bool {
+func (r *regexpExclude) shouldExclude(path string, isDir bool) bool {
 	if r.regex == nil {
 		r.buildRegex()
 	}
@@ -41,3 +46,260 @@ func (r *regexpExclude) buildRegex() {
 	}
 	r.regex = regexp.MustCompile(strings.Join(exclusions, "|"))
 }
+
+// IsPattern reports whether the exclusion uses glob/gitignore syntax (rather
+// than being a plain path). A leading slash anchors a pattern to the root of
+// each source.
+func IsPattern(exclusion string) bool {
+	return strings.HasPrefix(exclusion, "!") || strings.HasPrefix(exclusion, "/") ||
+		strings.ContainsAny(exclusion, "*?[")
+}
+
+// globExclude excludes files using the syntax of .gitignore files: `*`, `?`
+// and `[...]` match within a single path component and `**` matches any
+// number of components. A pattern containing a slash (other than a trailing
+// one) is anchored to the root of the source being walked, a trailing slash
+// only matches directories, and a leading `!` re-includes files excluded by an
+// earlier pattern. The last matching pattern wins.
+//
+// As with git, a file can't be re-included if one of its parent directories
+// is excluded.
+type globExclude struct {
+	rules globRules
+
+	// root is the path of the root of the source being walked (as it's
+	// shown), which patterns are relative to.
+	root     string
+	absolute bool
+
+	// dirs caches the result of each directory, which is checked for each of
+	// its descendants.
+	dirs map[string]bool
+}
+
+// globRule is a single compiled pattern of a globExclude.
+type globRule struct {
+	regex   *regexp.Regexp
+	negate  bool
+	dirOnly bool
+
+	// literal is set for a plain path, which is matched against the path of
+	// each file as it's shown (e.g. `repo/src`), rather than relative to the
+	// source's root.
+	literal bool
+
+	// absolute is set for an exclusion in the FROM clause with a leading
+	// slash, which may also be an absolute path, so it's matched against the
+	// path as it's shown too when the source is absolute.
+	absolute bool
+}
+
+// globRules is a list of patterns, in which the last matching pattern wins.
+type globRules []*globRule
+
+// newGlobExclude returns a globExclude for the given patterns.
+func newGlobExclude(patterns []string) *globExclude {
+	return &globExclude{rules: newGlobRules(patterns), dirs: make(map[string]bool)}
+}
+
+// forSource returns a copy of g for walking the source whose root is shown as
+// root.
+func (g *globExclude) forSource(root string) *globExclude {
+	absolute := filepath.IsAbs(root)
+	root = strings.Trim(filepath.ToSlash(filepath.Clean(root)), "/")
+	if root == "." {
+		root = ""
+	}
+	return &globExclude{rules: g.rules, root: root, absolute: absolute,
+		dirs: make(map[string]bool)}
+}
+
+// newGlobRules compiles each of the given patterns.
+func newGlobRules(patterns []string) globRules {
+	rules := make(globRules, 0, len(patterns))
+	for _, pattern := range patterns {
+		if rule := newGlobRule(pattern); rule != nil {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// newGlobRule compiles a single pattern, returning nil if the pattern can't
+// match anything (e.g. if it's empty or invalid).
+func newGlobRule(pattern string) *globRule {
+	rule := &globRule{}
+	if strings.HasPrefix(pattern, "!") {
+		rule.negate = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, `\!`) || strings.HasPrefix(pattern, `\#`) {
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return nil
+	}
+
+	prefix := "^(?:.*/)?"
+	if strings.Contains(pattern, "/") {
+		prefix = "^"
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+	regex, err := regexp.Compile(prefix + transform.GlobToRegexp(pattern) + "$")
+	if err != nil {
+		// e.g. an invalid character class, such as `[z-a]`.
+		return nil
+	}
+	rule.regex = regex
+	return rule
+}
+
+// shouldExclude returns true if path (or one of its parent directories) is
+// excluded by the last matching pattern. path is the path of the file as it's
+// shown.
+func (g *globExclude) shouldExclude(path string, isDir bool) bool {
+	path = strings.TrimLeft(filepath.ToSlash(path), "/")
+	if path == "" || path == "." || len(g.rules) == 0 {
+		return false
+	}
+
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' && g.excludesDir(path[:i]) {
+			return true
+		}
+	}
+	return g.matches(path, isDir)
+}
+
+// excludesDir returns true if the directory dir (but not necessarily its
+// parents) is excluded.
+func (g *globExclude) excludesDir(dir string) bool {
+	excluded, ok := g.dirs[dir]
+	if !ok {
+		excluded = g.matches(dir, true)
+		g.dirs[dir] = excluded
+	}
+	return excluded
+}
+
+// matches returns true if the last pattern matching path (as it's shown, and
+// without a leading slash) excludes it.
+func (g *globExclude) matches(path string, isDir bool) bool {
+	rel, inside := path, true
+	if g.root != "" {
+		rel, inside = strings.TrimPrefix(path, g.root+"/"), strings.HasPrefix(path, g.root+"/")
+	}
+	for i := len(g.rules) - 1; i >= 0; i-- {
+		rule := g.rules[i]
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if (rule.literal || rule.absolute && g.absolute) && rule.regex.MatchString(path) ||
+			!rule.literal && inside && rule.regex.MatchString(rel) {
+			return !rule.negate
+		}
+	}
+	return false
+}
+
+// match returns true if the last pattern matching path excludes it, rather
+// than re-including it. ok is false if no pattern matches path.
+func (r globRules) match(path string, isDir bool) (excluded, ok bool) {
+	for i := len(r) - 1; i >= 0; i-- {
+		rule := r[i]
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.regex.MatchString(path) {
+			return !rule.negate, true
+		}
+	}
+	return false, false
+}
+
+// readPatterns reads the exclusion patterns in the file name in fsys, which
+// uses the syntax of a .gitignore file. Blank lines and lines starting with
+// `#` are ignored, as are trailing spaces (unless escaped with a backslash).
+func readPatterns(fsys fs.FS, name string) ([]string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	patterns := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+			line = line[:len(line)-1]
+		}
+		if line != "" {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, scanner.Err()
+}
+
+// excluder returns the Excluder for this query's exclusions. Plain paths in
+// the FROM clause exclude the path and everything under it. If any exclusion
+// is a pattern, or patterns are loaded from a file (`-@file`), the exclusions
+// are matched as .gitignore patterns instead (relative to the root of each
+// source, see globExclude.forSource), other than plain paths, which are still
+// matched against the path of each file as it's shown.
+func (q *Query) excluder() (Excluder, error) {
+	exclusions := q.Sources["exclude"]
+	files := q.Sources["exclude-from"]
+
+	isGlob := len(files) > 0
+	for _, exclusion := range exclusions {
+		isGlob = isGlob || IsPattern(exclusion)
+	}
+	if !isGlob {
+		return &regexpExclude{exclusions: exclusions}, nil
+	}
+
+	rules := make(globRules, 0, len(exclusions))
+	for _, exclusion := range exclusions {
+		if IsPattern(exclusion) {
+			rule := newGlobRule(exclusion)
+			if rule != nil {
+				rule.absolute = strings.HasPrefix(exclusion, "/")
+				rules = append(rules, rule)
+			}
+			continue
+		}
+		rule := newGlobRule("/" + strings.TrimLeft(filepath.ToSlash(exclusion), "/"))
+		if rule != nil {
+			rule.literal = true
+			rules = append(rules, rule)
+		}
+	}
+	for _, file := range files {
+		fsys, name := q.locate(file)
+		filePatterns, err := readPatterns(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, newGlobRules(filePatterns)...)
+	}
+	return &globExclude{rules: rules, dirs: make(map[string]bool)}, nil
+}
+
+// excluders excludes a file if any of its Excluders do.
+type excluders []Excluder
+
+func (e excluders) shouldExclude(path string, isDir bool) bool {
+	for _, excluder := range e {
+		if excluder.shouldExclude(path, isDir) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,11 @@
 package query
 
-import "testing"
+import (
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
 
 func TestShouldExclude_ExpectAllExcluded(t *testing.T) {
 	type Case struct {
@@ -19,7 +24,7 @@ func TestShouldExclude_ExpectAllExcluded(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		actual := excluder.shouldExclude(c.input)
+		actual := excluder.shouldExclude(c.input, false)
 		if actual != c.expected {
 			t.Fatalf("\nExpected %v\n     Got %v", c.expected, actual)
 		}
@@ -43,9 +48,121 @@ func TestShouldExclude_ExpectNotExcluded(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		actual := excluder.shouldExclude(c.input)
+		actual := excluder.shouldExclude(c.input, false)
 		if actual != c.expected {
 			t.Fatalf("\nExpected %v\n     Got %v", c.expected, actual)
 		}
 	}
 }
+
+func TestGlobExclude_ShouldExclude(t *testing.T) {
+	type Case struct {
+		patterns []string
+		input    string
+		isDir    bool
+		expected bool
+	}
+
+	cases := []Case{
+		{patterns: []string{"*.log"}, input: "a.log", expected: true},
+		{patterns: []string{"*.log"}, input: "a/b/c.log", expected: true},
+		{patterns: []string{"*.log"}, input: "a.log.txt", expected: false},
+		{patterns: []string{"*.log", "!keep.log"}, input: "a/keep.log", expected: false},
+		{patterns: []string{"!keep.log", "*.log"}, input: "a/keep.log", expected: true},
+		{patterns: []string{"**/build/"}, input: "a/build", isDir: true, expected: true},
+		{patterns: []string{"**/build/"}, input: "a/build", expected: false},
+		{patterns: []string{"**/build/"}, input: "build/out/x.o", expected: true},
+		{patterns: []string{"build/", "!build/keep"}, input: "build/keep", expected: true},
+		{patterns: []string{"/docs"}, input: "docs/a.md", expected: true},
+		{patterns: []string{"/docs"}, input: "a/docs", expected: false},
+		{patterns: []string{"a/*.go"}, input: "a/b.go", expected: true},
+		{patterns: []string{"a/*.go"}, input: "a/b/c.go", expected: false},
+		{patterns: []string{"a/**/c.go"}, input: "a/c.go", expected: true},
+		{patterns: []string{"a/**/c.go"}, input: "a/b/d/c.go", expected: true},
+		{patterns: []string{"a/**"}, input: "a", isDir: true, expected: false},
+		{patterns: []string{"a/**"}, input: "a/b/c", expected: true},
+		{patterns: []string{"file.[ch]"}, input: "file.c", expected: true},
+		{patterns: []string{"file.[!ch]"}, input: "file.c", expected: false},
+		{patterns: []string{"file.?"}, input: "x/file.o", expected: true},
+		{patterns: []string{`\!important`}, input: "!important", expected: true},
+		{patterns: []string{"/tmp/x"}, input: "/tmp/x/y", expected: true},
+		{patterns: []string{"*"}, input: ".", isDir: true, expected: false},
+		{patterns: []string{"[z-a]"}, input: "a", expected: false},
+	}
+
+	for _, c := range cases {
+		actual := newGlobExclude(c.patterns).shouldExclude(c.input, c.isDir)
+		if actual != c.expected {
+			t.Fatalf("%v, %s\nExpected %v\n     Got %v", c.patterns, c.input, c.expected, actual)
+		}
+	}
+}
+
+func TestExcluder_Patterns(t *testing.T) {
+	fsys := fstest.MapFS{
+		".fsqlignore":   {Data: []byte("# build outputs\n*.o\n\n!keep.o  \nbuild/\n")},
+		"a.o":           {},
+		"a.c":           {},
+		"keep.o":        {},
+		"build/x.c":     {},
+		"logs/1.log":    {},
+		"src/main.c":    {},
+		"src/build/y.c": {},
+	}
+
+	q := NewQuery()
+	q.Sources["include"] = []string{"."}
+	q.Sources["exclude"] = []string{"*.log", ".fsqlignore"}
+	q.Sources["exclude-from"] = []string{".fsqlignore"}
+	q.Options = &Options{Jobs: 1, FS: fsys}
+
+	actual := make([]string, 0)
+	if err := q.Execute(
+		func(path string, info os.FileInfo, _ map[string]interface{}) {
+			actual = append(actual, path)
+		},
+	); err != nil {
+		t.Fatalf("\nExpected no error\n     Got %v", err)
+	}
+
+	expected := []string{"a.c", "keep.o", "logs", "src", "src/main.c"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("\nExpected %v\n     Got %v", expected, actual)
+	}
+
+	q.Sources["exclude-from"] = []string{"missing"}
+	if err := q.Execute(func(string, os.FileInfo, map[string]interface{}) {}); err == nil {
+		t.Fatalf("\nExpected missing: file does not exist\n     Got %v", err)
+	}
+}
+
+func TestExcluder_AnchoredPatterns(t *testing.T) {
+	fsys := fstest.MapFS{
+		"repo/main.c":      {},
+		"repo/a.log":       {},
+		"repo/src/a.c":     {},
+		"repo/lib/src/b.c": {},
+		"repo/docs/x.md":   {},
+		"src/c.c":          {},
+	}
+
+	q := NewQuery()
+	q.Sources["include"] = []string{"repo", "src"}
+	q.Sources["exclude"] = []string{"/src", "*.log", "repo/docs"}
+	q.Options = &Options{Jobs: 1, FS: fsys}
+
+	actual := make([]string, 0)
+	if err := q.Execute(
+		func(path string, info os.FileInfo, _ map[string]interface{}) {
+			actual = append(actual, path)
+		},
+	); err != nil {
+		t.Fatalf("\nExpected no error\n     Got %v", err)
+	}
+
+	expected := []string{"repo", "repo/lib", "repo/lib/src", "repo/lib/src/b.c",
+		"repo/main.c", "src", "src/c.c"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("\nExpected %v\n     Got %v", expected, actual)
+	}
+}
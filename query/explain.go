@@ -0,0 +1,302 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kashav/fsql/tokenizer"
+)
+
+// operatorNames holds the name of each operator, as it's written in a query.
+var operatorNames = map[tokenizer.TokenType]string{
+	tokenizer.In:                "IN",
+	tokenizer.Is:                "IS",
+	tokenizer.Like:              "LIKE",
+	tokenizer.ILike:             "ILIKE",
+	tokenizer.RLike:             "RLIKE",
+	tokenizer.Glob:              "GLOB",
+	tokenizer.Contains:          "CONTAINS",
+	tokenizer.Equals:            "=",
+	tokenizer.NotEquals:         "<>",
+	tokenizer.GreaterThanEquals: ">=",
+	tokenizer.GreaterThan:       ">",
+	tokenizer.LessThanEquals:    "<=",
+	tokenizer.LessThan:          "<",
+}
+
+// Plan returns a description of how this query is executed, as printed by
+// `EXPLAIN SELECT ...`. It lists the selected columns (with their modifiers),
+// the sources (with their options) and exclusions, the condition tree in the
+// order it's evaluated (with its precedence made explicit, and the conditions
+// which prune directories from the walk), and how each subquery is evaluated.
+//
+// Nothing is executed or read from disk, so the plan is the same as the one
+// used by Execute, other than for archives detected by their contents.
+func (q *Query) Plan() string {
+	var b strings.Builder
+
+	b.WriteString("SELECT\n")
+	for _, attribute := range q.Attributes {
+		fmt.Fprintf(&b, "  %s\n", formatAttribute(attribute, q.Modifiers[attribute]))
+	}
+
+	b.WriteString("FROM\n")
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, src := range q.Sources["include"] {
+		if notes := q.sourceNotes(src); len(notes) > 0 {
+			fmt.Fprintf(w, "  %s\t%s\n", src, strings.Join(notes, ", "))
+		} else {
+			fmt.Fprintf(w, "  %s\n", src)
+		}
+	}
+	w.Flush()
+
+	if exclusions := q.exclusionNotes(); len(exclusions) > 0 {
+		b.WriteString("EXCLUDE\n")
+		w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		for _, exclusion := range exclusions {
+			fmt.Fprintf(w, "  %s\t%s\n", exclusion[0], exclusion[1])
+		}
+		w.Flush()
+	}
+
+	if q.ConditionTree == nil {
+		return b.String()
+	}
+
+	root := q.ConditionTree.optimize()
+	fmt.Fprintf(&b, "WHERE %s\n", root.format(true))
+	pruning := make(map[*Condition]bool)
+	for _, c := range root.pruning() {
+		pruning[c] = true
+	}
+	w = tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	root.writeTree(w, "  ", "", pruning)
+	w.Flush()
+
+	if subqueries := root.subqueries(); len(subqueries) > 0 {
+		b.WriteString("SUBQUERIES\n")
+		for _, c := range subqueries {
+			fmt.Fprintf(&b, "  %s\n", c)
+			b.WriteString(indent(c.subqueryNote()+"\n", "    "))
+			if c.Subquery != nil {
+				b.WriteString(indent(c.Subquery.Plan(), "    "))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// sourceNotes returns the notes on how the source src is walked, i.e. its
+// alias and options (including those which apply to every source).
+func (q *Query) sourceNotes(src string) []string {
+	notes := make([]string, 0)
+	aliases := make([]string, 0)
+	for alias, source := range q.SourceAliases {
+		if source == src {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		notes = append(notes, "AS "+alias)
+	}
+
+	if isGlob(src) {
+		notes = append(notes, fmt.Sprintf("glob, walks %s", newGlob(src).base))
+	}
+
+	opts, global := q.sourceOptions(src), q.options()
+	if opts.Archive {
+		notes = append(notes, "ARCHIVE")
+	}
+	if opts.GitIgnore || global.IgnoreVCS {
+		notes = append(notes, "USING GITIGNORE")
+	}
+	if opts.FollowLinks || global.FollowLinks {
+		notes = append(notes, "FOLLOW LINKS")
+	}
+	if opts.OneFilesystem || global.OneFilesystem {
+		notes = append(notes, "ONE FILESYSTEM")
+	}
+	limits := q.depthLimits(&source{path: src}, opts)
+	if limits.min > 0 {
+		notes = append(notes, fmt.Sprintf("MINDEPTH %d", limits.min))
+	}
+	if limits.max > 0 {
+		notes = append(notes, fmt.Sprintf("MAXDEPTH %d", limits.max))
+	}
+	return notes
+}
+
+// exclusionNotes returns each exclusion, along with how it's matched (see
+// excluder).
+func (q *Query) exclusionNotes() [][2]string {
+	exclusions := q.Sources["exclude"]
+	files := q.Sources["exclude-from"]
+
+	notes := make([][2]string, 0, len(exclusions)+len(files))
+	for _, exclusion := range exclusions {
+		switch pattern := strings.TrimRight(strings.TrimPrefix(exclusion, "!"), "/"); {
+		case !IsPattern(exclusion):
+			notes = append(notes, [2]string{exclusion, "path, and everything under it"})
+		case strings.Contains(pattern, "/"):
+			notes = append(notes, [2]string{exclusion, "pattern, anchored to the root of each source"})
+		default:
+			notes = append(notes, [2]string{exclusion, "pattern"})
+		}
+	}
+	for _, file := range files {
+		notes = append(notes, [2]string{"@" + file, "patterns read from file"})
+	}
+	return notes
+}
+
+// format returns the tree rooted at root as a single expression, with each
+// group of ANDs (or ORs) other than the outermost one (if top is set) in
+// parentheses.
+func (root *ConditionNode) format(top bool) string {
+	if root.Condition != nil {
+		return root.Condition.String()
+	}
+
+	operands := root.group(*root.Type)
+	formatted := make([]string, len(operands))
+	for i, operand := range operands {
+		formatted[i] = operand.format(false)
+	}
+	s := strings.Join(formatted, fmt.Sprintf(" %s ", strings.ToUpper(root.Type.String())))
+	if top {
+		return s
+	}
+	return "(" + s + ")"
+}
+
+// writeTree writes a line for each node of the tree rooted at root to w, along
+// with the cost of each node and whether each condition prunes directories.
+// prefix is written before the line of each node and branch before the line
+// of root (i.e. its position among its siblings).
+func (root *ConditionNode) writeTree(w *tabwriter.Writer, prefix, branch string,
+	pruning map[*Condition]bool) {
+	note := fmt.Sprintf("cost %d", root.cost())
+	if root.Condition != nil {
+		if pruning[root.Condition] {
+			note += ", prunes directories"
+		}
+		fmt.Fprintf(w, "%s%s%s\t%s\n", prefix, branch, root.Condition, note)
+		return
+	}
+
+	fmt.Fprintf(w, "%s%s%s\t%s\n", prefix, branch, strings.ToUpper(root.Type.String()), note)
+	switch branch {
+	case "├── ":
+		prefix += "│   "
+	case "└── ":
+		prefix += "    "
+	}
+	operands := root.group(*root.Type)
+	for i, operand := range operands {
+		branch := "├── "
+		if i == len(operands)-1 {
+			branch = "└── "
+		}
+		operand.writeTree(w, prefix, branch, pruning)
+	}
+}
+
+// subqueries returns the conditions of the tree rooted at root which test a
+// subquery that hasn't been evaluated, in the order they're evaluated.
+func (root *ConditionNode) subqueries() []*Condition {
+	subqueries := make([]*Condition, 0)
+	for _, c := range root.conditions() {
+		if c.IsSubquery {
+			subqueries = append(subqueries, c)
+		}
+	}
+	return subqueries
+}
+
+// subqueryNote returns a note on how this condition's subquery is evaluated.
+func (c *Condition) subqueryNote() string {
+	if c.correlated() {
+		return "uses source aliases, so it's run against each file (not supported yet)"
+	}
+	return fmt.Sprintf("runs once, before the walk; %s is tested against its results",
+		formatAttribute(c.Attribute, c.AttributeModifiers))
+}
+
+// String returns this condition as it's written in a query.
+func (c *Condition) String() string {
+	var b strings.Builder
+	if c.Negate {
+		b.WriteString("NOT ")
+	}
+	b.WriteString(formatAttribute(c.Attribute, c.AttributeModifiers))
+
+	operator, ok := operatorNames[c.Operator]
+	if !ok {
+		operator = strings.ToUpper(c.Operator.String())
+	}
+	fmt.Fprintf(&b, " %s %s", operator, c.formatValue())
+
+	if c.Escape != 0 {
+		fmt.Fprintf(&b, " ESCAPE '%c'", c.Escape)
+	}
+	return b.String()
+}
+
+// formatValue returns the value of this condition as it's written in a query.
+func (c *Condition) formatValue() string {
+	switch v := c.Value.(type) {
+	case string:
+		if c.IsSubquery {
+			return "(" + v + ")"
+		}
+		return quote(v)
+	case []string:
+		values := make([]string, len(v))
+		for i, value := range v {
+			values[i] = quote(value)
+		}
+		return "[" + strings.Join(values, ", ") + "]"
+	case map[interface{}]bool:
+		return fmt.Sprintf("(%d subquery results)", len(v))
+	}
+	return fmt.Sprintf("%v", c.Value)
+}
+
+// formatAttribute returns attribute with each of its modifiers applied, as
+// it's written in a query (e.g. `FORMAT(size, KB)`).
+func formatAttribute(attribute string, modifiers []Modifier) string {
+	s := attribute
+	for _, m := range modifiers {
+		s = fmt.Sprintf("%s(%s)", m.Name, strings.Join(append([]string{s}, m.Arguments...), ", "))
+	}
+	return s
+}
+
+// quote returns s as a single value in a query, i.e. in quotes if it's empty
+// or it would otherwise be split into several tokens.
+func quote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"`,()[]<>=!") {
+		return s
+	}
+	if strings.Contains(s, "'") {
+		return `"` + s + `"`
+	}
+	return "'" + s + "'"
+}
+
+// indent prefixes each line of s with prefix.
+func indent(s, prefix string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,131 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/kashav/fsql/tokenizer"
+)
+
+func TestExplain_ConditionString(t *testing.T) {
+	type Case struct {
+		input    *Condition
+		expected string
+	}
+
+	cases := []Case{
+		{
+			input:    &Condition{Attribute: "name", Operator: tokenizer.Like, Value: "%.go"},
+			expected: "name LIKE %.go",
+		},
+		{
+			input: &Condition{
+				Attribute:          "name",
+				AttributeModifiers: []Modifier{{Name: "UPPER"}, {Name: "FORMAT", Arguments: []string{"x"}}},
+				Operator:           tokenizer.NotEquals,
+				Value:              "a b",
+				Negate:             true,
+			},
+			expected: "NOT FORMAT(UPPER(name), x) <> 'a b'",
+		},
+		{
+			input:    &Condition{Attribute: "name", Operator: tokenizer.Like, Value: "100!%%", Escape: '!'},
+			expected: "name LIKE '100!%%' ESCAPE '!'",
+		},
+		{
+			input:    &Condition{Attribute: "name", Operator: tokenizer.In, Value: []string{"a", "it's"}},
+			expected: `name IN [a, "it's"]`,
+		},
+		{
+			input: &Condition{
+				Attribute:  "size",
+				Operator:   tokenizer.In,
+				Value:      "SELECT size FROM .",
+				IsSubquery: true,
+			},
+			expected: "size IN (SELECT size FROM .)",
+		},
+		{
+			input:    &Condition{Attribute: "size", Operator: tokenizer.GreaterThanEquals, Value: float64(10)},
+			expected: "size >= 10",
+		},
+	}
+
+	for _, c := range cases {
+		if actual := c.input.String(); actual != c.expected {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected, actual)
+		}
+	}
+}
+
+func TestExplain_Plan(t *testing.T) {
+	var (
+		and = tokenizer.And
+		or  = tokenizer.Or
+	)
+
+	leaf := func(attribute string, op tokenizer.TokenType, value interface{},
+		negate bool) *ConditionNode {
+		return &ConditionNode{Condition: &Condition{
+			Attribute: attribute,
+			Operator:  op,
+			Value:     value,
+			Negate:    negate,
+		}}
+	}
+
+	subquery := NewQuery()
+	subquery.Attributes = []string{"name"}
+	subquery.Sources["include"] = []string{"lib"}
+
+	q := NewQuery()
+	q.Attributes = []string{"name", "size"}
+	q.Modifiers["size"] = []Modifier{{Name: "FORMAT", Arguments: []string{"KB"}}}
+	q.Sources["include"] = []string{".", "src/**/*.go"}
+	q.Sources["exclude"] = []string{".git", "*.log", "/build/"}
+	q.SourceAliases["s"] = "src/**/*.go"
+	q.SourceOptions["."] = &SourceOptions{GitIgnore: true, MaxDepth: 2}
+	q.Options = &Options{FollowLinks: true}
+	q.ConditionTree = &ConditionNode{
+		Type: &and,
+		Left: &ConditionNode{
+			Type:  &and,
+			Left:  leaf("hash", tokenizer.Equals, "abc", false),
+			Right: &ConditionNode{Type: &or, Left: leaf("size", tokenizer.GreaterThan, "10", false), Right: leaf("name", tokenizer.Equals, "main.go", false)},
+		},
+		Right: &ConditionNode{
+			Type:  &and,
+			Left:  leaf("path", tokenizer.Like, "%/vendor/%", true),
+			Right: &ConditionNode{Condition: &Condition{Attribute: "name", Operator: tokenizer.In, Value: "SELECT name FROM lib", IsSubquery: true, Subquery: subquery}},
+		},
+	}
+
+	expected := `SELECT
+  name
+  FORMAT(size, KB)
+FROM
+  .            USING GITIGNORE, FOLLOW LINKS, MAXDEPTH 2
+  src/**/*.go  AS s, glob, walks src, FOLLOW LINKS
+EXCLUDE
+  .git     path, and everything under it
+  *.log    pattern
+  /build/  pattern, anchored to the root of each source
+WHERE NOT path LIKE %/vendor/% AND name IN (SELECT name FROM lib) AND (size > 10 OR name = main.go) AND hash = abc
+  AND                                 cost 104
+  ├── NOT path LIKE %/vendor/%        cost 1, prunes directories
+  ├── name IN (SELECT name FROM lib)  cost 1
+  ├── OR                              cost 2
+  │   ├── size > 10                   cost 1
+  │   └── name = main.go              cost 1
+  └── hash = abc                      cost 100
+SUBQUERIES
+  name IN (SELECT name FROM lib)
+    runs once, before the walk; name is tested against its results
+    SELECT
+      name
+    FROM
+      lib
+`
+	if actual := q.Plan(); actual != expected {
+		t.Fatalf("\nExpected %v\n     Got %v", expected, actual)
+	}
+}
@@ -0,0 +1,197 @@
+package query
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kashav/fsql/transform"
+)
+
+// ignoreFiles are the files read from each directory by gitIgnore. Patterns
+// in later files take precedence.
+var ignoreFiles = []string{".gitignore", ".ignore", ".fsqlignore"}
+
+// gitIgnore excludes the files of a single source that git would ignore. The
+// ignore files in each directory (see ignoreFiles) apply to the directory's
+// contents, with patterns in deeper directories taking precedence. Inside a
+// repository, the ignore files of the source's parent directories (up to the
+// repository's root) apply as well, followed by .git/info/exclude and the
+// global excludes file. The .git directory itself is always excluded.
+type gitIgnore struct {
+	fsys fs.FS
+
+	// root is the name in fsys of the outermost directory whose ignore files
+	// are read (the repository's root, or the source's root outside of a
+	// repository), base is the name of the source's root, and path is the path
+	// that's shown for base.
+	root, base, path string
+
+	// exclude holds the patterns of .git/info/exclude and the global excludes
+	// file, which are relative to root.
+	exclude globRules
+
+	// dirs caches the patterns read from each directory.
+	dirs map[string]globRules
+}
+
+// newGitIgnore returns a gitIgnore for the source s. global holds the patterns
+// of the global excludes file.
+func (q *Query) newGitIgnore(s *source, global globRules) *gitIgnore {
+	fsys, base := s.fsys, s.root
+
+	// The repository may be rooted above the source, which is outside of the
+	// source's filesystem, so OS paths are read from the root of the volume.
+	if s.container == "" && q.options().FS == nil {
+		if abs, err := filepath.Abs(s.path); err == nil {
+			vol := filepath.VolumeName(abs)
+			fsys = transform.DirFS(vol + string(filepath.Separator))
+			if base = strings.Trim(filepath.ToSlash(abs[len(vol):]), "/"); base == "" {
+				base = "."
+			}
+		}
+	}
+
+	g := &gitIgnore{
+		fsys: fsys,
+		root: base,
+		base: base,
+		path: s.path,
+		dirs: make(map[string]globRules),
+	}
+	if root, ok := findRepository(fsys, base); ok {
+		g.root = root
+		patterns, _ := readPatterns(fsys, pathpkg.Join(root, ".git/info/exclude"))
+		g.exclude = append(append(globRules{}, global...), newGlobRules(patterns)...)
+	}
+	return g
+}
+
+// findRepository returns the name of the root of the git repository that
+// contains the directory name in fsys.
+func findRepository(fsys fs.FS, name string) (string, bool) {
+	for dir := name; ; dir = pathpkg.Dir(dir) {
+		if _, err := fs.Stat(fsys, pathpkg.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		if dir == "." {
+			return "", false
+		}
+	}
+}
+
+// shouldExclude returns true if git would ignore the file located at path.
+// Since ignored directories are never descended into, the file's parent
+// directories aren't checked.
+func (g *gitIgnore) shouldExclude(path string, isDir bool) bool {
+	rel, err := filepath.Rel(g.path, path)
+	if err != nil || rel == "." {
+		return false
+	}
+	if isDir && filepath.Base(rel) == ".git" {
+		return true
+	}
+
+	name := pathpkg.Join(g.base, filepath.ToSlash(rel))
+	for dir := pathpkg.Dir(name); ; dir = pathpkg.Dir(dir) {
+		if excluded, ok := g.rules(dir).match(relativeTo(dir, name), isDir); ok {
+			return excluded
+		}
+		if dir == g.root || dir == "." {
+			break
+		}
+	}
+	excluded, _ := g.exclude.match(relativeTo(g.root, name), isDir)
+	return excluded
+}
+
+// rules returns the patterns read from the ignore files in the directory dir.
+// Ignore files which can't be read are skipped.
+func (g *gitIgnore) rules(dir string) globRules {
+	rules, ok := g.dirs[dir]
+	if !ok {
+		patterns := make([]string, 0)
+		for _, file := range ignoreFiles {
+			filePatterns, _ := readPatterns(g.fsys, pathpkg.Join(dir, file))
+			patterns = append(patterns, filePatterns...)
+		}
+		rules = newGlobRules(patterns)
+		g.dirs[dir] = rules
+	}
+	return rules
+}
+
+// relativeTo returns the slash-separated name relative to the directory dir,
+// which contains it.
+func relativeTo(dir, name string) string {
+	if dir == "." {
+		return name
+	}
+	return strings.TrimPrefix(name, dir+"/")
+}
+
+// globalExcludes returns the patterns of git's global excludes file, which is
+// set by core.excludesFile, and is otherwise $XDG_CONFIG_HOME/git/ignore.
+func globalExcludes() globRules {
+	home, _ := os.UserHomeDir()
+	config := os.Getenv("XDG_CONFIG_HOME")
+	if config == "" && home != "" {
+		config = filepath.Join(home, ".config")
+	}
+
+	file := ""
+	if config != "" {
+		file = filepath.Join(config, "git", "ignore")
+		if value, ok := readExcludesFile(filepath.Join(config, "git", "config")); ok {
+			file = value
+		}
+	}
+	if home != "" {
+		if value, ok := readExcludesFile(filepath.Join(home, ".gitconfig")); ok {
+			file = value
+		}
+	}
+	if file == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(file, "~/") && home != "" {
+		file = filepath.Join(home, file[2:])
+	}
+	patterns, _ := readPatterns(transform.DirFS(filepath.Dir(file)), filepath.Base(file))
+	return newGlobRules(patterns)
+}
+
+// readExcludesFile returns the value of core.excludesFile in the git config
+// file located at path, if it's set.
+func readExcludesFile(path string) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	var (
+		section string
+		value   string
+		ok      bool
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			section = strings.ToLower(strings.TrimSpace(strings.Trim(line, "[]")))
+			continue
+		}
+		key, v, found := strings.Cut(line, "=")
+		if !found || section != "core" ||
+			!strings.EqualFold(strings.TrimSpace(key), "excludesFile") {
+			continue
+		}
+		value, ok = strings.Trim(strings.TrimSpace(v), `"`), true
+	}
+	return value, ok
+}
@@ -0,0 +1,101 @@
+package query
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGitIgnore_Execute(t *testing.T) {
+	type Case struct {
+		sources  []string
+		options  map[string]*SourceOptions
+		vcs      bool
+		expected []string
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	os.WriteFile(filepath.Join(home, ".gitconfig"),
+		[]byte("[user]\n\tname = x\n[core]\n\texcludesFile = ~/global-ignore\n"), 0644)
+	os.WriteFile(filepath.Join(home, "global-ignore"), []byte("*.swp\n"), 0644)
+
+	fsys := fstest.MapFS{
+		"repo/.git/HEAD":             {},
+		"repo/.git/info/exclude":     {Data: []byte("local.txt\n")},
+		"repo/.gitignore":            {Data: []byte("*.o\n/build/\nlogs/\n")},
+		"repo/a.c":                   {},
+		"repo/a.o":                   {},
+		"repo/a.c.swp":               {},
+		"repo/local.txt":             {},
+		"repo/build/x":               {},
+		"repo/src/.gitignore":        {Data: []byte("!keep.o\ngen/*\n!gen/main.c\n")},
+		"repo/src/build/y.c":         {},
+		"repo/src/keep.o":            {},
+		"repo/src/logs":              {Data: []byte("not a directory")},
+		"repo/src/gen/main.c":        {},
+		"repo/src/gen/other.c":       {},
+		"repo/src/.fsqlignore":       {Data: []byte("*.c\n!main.c\n")},
+		"repo/src/nested/logs/1.log": {},
+		"repo/src/nested/.ignore":    {Data: []byte("!*.o\n")},
+		"repo/src/nested/b.o":        {},
+		"plain/.gitignore":           {Data: []byte("*.tmp\n")},
+		"plain/a.tmp":                {},
+		"plain/b.swp":                {},
+		"plain/sub/c.tmp":            {},
+		"plain/sub/d":                {},
+	}
+
+	src := []string{"repo/.gitignore", "repo/src", "repo/src/.fsqlignore",
+		"repo/src/.gitignore", "repo/src/build", "repo/src/gen",
+		"repo/src/gen/main.c", "repo/src/keep.o", "repo/src/logs",
+		"repo/src/nested", "repo/src/nested/.ignore", "repo/src/nested/b.o"}
+
+	cases := []Case{
+		{
+			sources:  []string{"repo"},
+			vcs:      true,
+			expected: append([]string{"repo", "repo/.gitignore", "repo/a.c"}, src[1:]...),
+		},
+		{
+			sources:  []string{"repo/src"},
+			options:  map[string]*SourceOptions{"repo/src": {GitIgnore: true}},
+			expected: src[1:],
+		},
+		{
+			sources: []string{"plain"},
+			vcs:     true,
+			expected: []string{"plain", "plain/.gitignore", "plain/b.swp",
+				"plain/sub", "plain/sub/d"},
+		},
+		{
+			sources: []string{"plain"},
+			expected: []string{"plain", "plain/.gitignore", "plain/a.tmp",
+				"plain/b.swp", "plain/sub", "plain/sub/c.tmp", "plain/sub/d"},
+		},
+	}
+
+	for _, c := range cases {
+		q := NewQuery()
+		q.Sources["include"] = c.sources
+		if c.options != nil {
+			q.SourceOptions = c.options
+		}
+		q.Options = &Options{Jobs: 1, IgnoreVCS: c.vcs, FS: fsys}
+
+		actual := make([]string, 0)
+		if err := q.Execute(
+			func(path string, info os.FileInfo, _ map[string]interface{}) {
+				actual = append(actual, filepath.ToSlash(path))
+			},
+		); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+		if !reflect.DeepEqual(c.expected, actual) {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package query
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kashav/fsql/transform"
+)
+
+// globMeta holds the characters which make a source a glob pattern.
+const globMeta = "*?[{"
+
+// isGlob reports whether the source src is a glob pattern.
+func isGlob(src string) bool {
+	return strings.ContainsAny(src, globMeta)
+}
+
+// glob is a source which is a glob pattern. Rather than expanding the pattern
+// up front, the pattern's base (the leading directories without any special
+// characters) is walked once, and the pattern filters the files in the walk.
+//
+// Patterns use the same syntax as GLOB conditions and exclusions (see
+// transform.GlobToRegexp), along with `{a,b}` (which matches either
+// alternative, and may be nested). As with a plain source, each file matching
+// the pattern is visited along with its contents.
+type glob struct {
+	// base is the path of the directory that's walked.
+	base string
+
+	// patterns holds the components of each alternative of the pattern,
+	// relative to base, and regexps holds the compiled pattern of each
+	// component other than `**` (or nil, if it's invalid).
+	patterns [][]string
+	regexps  map[string]*regexp.Regexp
+}
+
+// newGlob returns the glob for the pattern src.
+func newGlob(src string) *glob {
+	parts := strings.Split(strings.TrimRight(filepath.ToSlash(src), "/"), "/")
+	n := 0
+	for n < len(parts)-1 && !strings.ContainsAny(parts[n], globMeta+`\`) {
+		n++
+	}
+
+	base := strings.Join(parts[:n], "/")
+	if base == "" && n > 0 {
+		base = "/"
+	} else if base == "" {
+		base = "."
+	}
+
+	g := &glob{
+		base:    filepath.Clean(filepath.FromSlash(base)),
+		regexps: make(map[string]*regexp.Regexp),
+	}
+	for _, pattern := range expandBraces(strings.Join(parts[n:], "/")) {
+		components := make([]string, 0)
+		for _, component := range strings.Split(pattern, "/") {
+			if component == "" {
+				continue
+			}
+			components = append(components, component)
+			if _, ok := g.regexps[component]; !ok && component != "**" {
+				g.regexps[component], _ = regexp.Compile(
+					"^" + transform.GlobToRegexp(component) + "$")
+			}
+		}
+		g.patterns = append(g.patterns, components)
+	}
+	return g
+}
+
+// match returns the path of the outermost directory (or file) containing the
+// file located at path which matches the pattern, which is the file itself if
+// it matches. Returns false if neither the file nor any of its parents (below
+// the base) match.
+func (g *glob) match(path string) (string, bool) {
+	names, ok := g.split(path)
+	if !ok {
+		return "", false
+	}
+	for i := 0; i <= len(names); i++ {
+		for _, pattern := range g.patterns {
+			if g.matchComponents(pattern, names[:i], false) {
+				return filepath.Join(g.base, filepath.Join(names[:i]...)), true
+			}
+		}
+	}
+	return "", false
+}
+
+// mayContain reports whether the directory located at path may contain a file
+// which matches the pattern, i.e. whether it should be descended into.
+func (g *glob) mayContain(path string) bool {
+	names, ok := g.split(path)
+	if !ok {
+		return false
+	}
+	for _, pattern := range g.patterns {
+		if g.matchComponents(pattern, names, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// split returns the components of path, relative to the base. Returns false
+// if path isn't inside the base.
+func (g *glob) split(path string) ([]string, bool) {
+	rel, err := filepath.Rel(g.base, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, false
+	}
+	if rel == "." {
+		return []string{}, true
+	}
+	return strings.Split(filepath.ToSlash(rel), "/"), true
+}
+
+// matchComponents reports whether names matches the components of pattern.
+// If partial is set, names only has to match a leading part of pattern (i.e.
+// a file in the directory names could match the full pattern).
+func (g *glob) matchComponents(pattern, names []string, partial bool) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(names); i++ {
+				if g.matchComponents(pattern[1:], names[i:], partial) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return partial
+		}
+		if re := g.regexps[pattern[0]]; re == nil || !re.MatchString(names[0]) {
+			return false
+		}
+		pattern, names = pattern[1:], names[1:]
+	}
+	return len(names) == 0
+}
+
+// expandBraces returns each alternative of pattern, expanding each `{a,b}`
+// (outside of a character class) into a and b. Unbalanced braces are left as
+// they are.
+func expandBraces(pattern string) []string {
+	depth, start := 0, 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '[':
+			if end := strings.IndexByte(pattern[i+1:], ']'); end >= 0 {
+				i += end + 1
+			}
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			if depth--; depth > 0 {
+				continue
+			}
+			expanded := make([]string, 0)
+			for _, alternative := range splitAlternatives(pattern[start+1 : i]) {
+				expanded = append(expanded,
+					expandBraces(pattern[:start]+alternative+pattern[i+1:])...)
+			}
+			return expanded
+		}
+	}
+	return []string{pattern}
+}
+
+// splitAlternatives splits the contents of a pair of braces at each comma
+// which isn't inside nested braces.
+func splitAlternatives(s string) []string {
+	alternatives := make([]string, 0)
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				alternatives = append(alternatives, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(alternatives, s[start:])
+}
@@ -0,0 +1,114 @@
+package query
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGlob_NewGlob(t *testing.T) {
+	type Expected struct {
+		base     string
+		patterns [][]string
+	}
+
+	type Case struct {
+		input    string
+		expected Expected
+	}
+
+	cases := []Case{
+		{
+			input:    "*.go",
+			expected: Expected{base: ".", patterns: [][]string{{"*.go"}}},
+		},
+		{
+			input:    "./src/**/*.go",
+			expected: Expected{base: "src", patterns: [][]string{{"**", "*.go"}}},
+		},
+		{
+			input:    "/usr/*/bin/",
+			expected: Expected{base: "/usr", patterns: [][]string{{"*", "bin"}}},
+		},
+		{
+			input:    "/*",
+			expected: Expected{base: "/", patterns: [][]string{{"*"}}},
+		},
+		{
+			input: "src/{cmd,lib/{a,b}}/*.[ch]",
+			expected: Expected{base: "src", patterns: [][]string{
+				{"cmd", "*.[ch]"},
+				{"lib", "a", "*.[ch]"},
+				{"lib", "b", "*.[ch]"},
+			}},
+		},
+		{
+			input:    "a/[{]b",
+			expected: Expected{base: "a", patterns: [][]string{{"[{]b"}}},
+		},
+	}
+
+	for _, c := range cases {
+		g := newGlob(c.input)
+		actual := Expected{base: g.base, patterns: g.patterns}
+		if !reflect.DeepEqual(c.expected, actual) {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected, actual)
+		}
+	}
+}
+
+func TestGlob_Execute(t *testing.T) {
+	fsys := &deniedFS{
+		MapFS: fstest.MapFS{
+			"a/1.txt":          {},
+			"a/2.md":           {},
+			"b/3.txt":          {},
+			"b/sub/4.txt":      {},
+			"c/5.txt":          {},
+			"src/main.go":      {},
+			"src/x/y/z.go":     {},
+			"src/x/y/z.c":      {},
+			"src/vendor/v.go":  {},
+			"src/vendor/v/w.c": {},
+		},
+		// Reading a directory which can't contain any match fails the test.
+		denied: map[string]bool{"c": true},
+	}
+
+	type Case struct {
+		source   string
+		maxDepth int
+		expected []string
+	}
+
+	cases := []Case{
+		{source: "{a,b}/*.txt", expected: []string{"a/1.txt", "b/3.txt"}},
+		{source: "[ab]/*.{md,txt}", expected: []string{"a/1.txt", "a/2.md", "b/3.txt"}},
+		{source: "b/**/*.txt", expected: []string{"b/3.txt", "b/sub/4.txt"}},
+		{source: "src/**/*.go", expected: []string{"src/main.go", "src/vendor/v.go", "src/x/y/z.go"}},
+		{source: "src/**.go", expected: []string{"src/main.go"}},
+		{source: "src/x/**", expected: []string{"src/x", "src/x/y", "src/x/y/z.c", "src/x/y/z.go"}},
+		{source: "src/v*", expected: []string{"src/vendor", "src/vendor/v", "src/vendor/v/w.c", "src/vendor/v.go"}},
+		{source: "src/v*", maxDepth: 1, expected: []string{"src/vendor", "src/vendor/v", "src/vendor/v.go"}},
+		{source: "missing/*", expected: []string{}},
+	}
+
+	for _, c := range cases {
+		q := NewQuery()
+		q.Sources["include"] = []string{c.source}
+		q.Options = &Options{Jobs: 1, MaxDepth: c.maxDepth, FS: fsys}
+
+		actual := make([]string, 0)
+		if err := q.Execute(
+			func(path string, info os.FileInfo, _ map[string]interface{}) {
+				actual = append(actual, path)
+			},
+		); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+		if !reflect.DeepEqual(c.expected, actual) {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected, actual)
+		}
+	}
+}
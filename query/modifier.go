@@ -1,8 +1,10 @@
 package query
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
 
 	"github.com/kashav/fsql/transform"
@@ -12,29 +14,53 @@ import (
 type Modifier struct {
 	Name      string
 	Arguments []string
+
+	// Pattern is the compiled pattern of Arguments, for modifiers which use one
+	// (e.g. MATCHES). Set when the query is prepared (see prepareModifiers).
+	Pattern *regexp.Regexp
 }
 
 func (m *Modifier) String() string {
 	return fmt.Sprintf("%s(%s)", m.Name, strings.Join(m.Arguments, ", "))
 }
 
+// prepareModifiers compiles the pattern of each SELECT modifier which uses
+// one (e.g. MATCHES), so that it isn't compiled again for each file.
+func (q *Query) prepareModifiers() error {
+	for _, modifiers := range q.Modifiers {
+		for i, m := range modifiers {
+			re, err := transform.CompileModifier(m.Name, m.Arguments)
+			if err != nil {
+				return err
+			}
+			modifiers[i].Pattern = re
+		}
+	}
+	return nil
+}
+
 // applyModifiers iterates through each SELECT attribute for this query
-// and applies the associated modifier to the attribute's output value.
-func (q *Query) applyModifiers(path string, info os.FileInfo) (map[string]interface{}, error) {
+// and applies the associated modifier to the attribute's output value. Values
+// in computed are used in place of the attribute's default format value, which
+// isn't computed if the first modifier reads the file itself (e.g. SHA1).
+// Reading the file stops once ctx is done.
+func (q *Query) applyModifiers(ctx context.Context, path string, info os.FileInfo,
+	computed map[string]interface{}) (map[string]interface{}, error) {
 	results := make(map[string]interface{}, len(q.Attributes))
 
 	for _, attribute := range q.Attributes {
-		value, err := transform.DefaultFormatValue(attribute, path, info)
-		if err != nil {
-			return map[string]interface{}{}, err
-		}
-
-		if _, ok := q.Modifiers[attribute]; !ok {
-			results[attribute] = value
-			continue
+		modifiers := q.Modifiers[attribute]
+		value, ok := computed[attribute]
+		if !ok && (len(modifiers) == 0 || !transform.ReadsFile(modifiers[0].Name)) {
+			var err error
+			value, err = transform.DefaultFormatValueContext(ctx, attribute, path, info)
+			if err != nil {
+				return map[string]interface{}{}, err
+			}
 		}
 
-		for _, m := range q.Modifiers[attribute] {
+		for _, m := range modifiers {
+			var err error
 			value, err = transform.Format(&transform.FormatParams{
 				Attribute: attribute,
 				Path:      path,
@@ -42,6 +68,8 @@ func (q *Query) applyModifiers(path string, info os.FileInfo) (map[string]interf
 				Value:     value,
 				Name:      m.Name,
 				Args:      m.Arguments,
+				Pattern:   m.Pattern,
+				Context:   ctx,
 			})
 			if err != nil {
 				return map[string]interface{}{}, err
@@ -1,6 +1,13 @@
 package query
 
-import "testing"
+import (
+	"context"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kashav/fsql/transform"
+)
 
 func TestModifier_String(t *testing.T) {
 	type Case struct {
@@ -27,6 +34,80 @@ func TestModifier_String(t *testing.T) {
 	}
 }
 
+// byteCountingFS is a filesystem which counts the bytes read from its files.
+type byteCountingFS struct {
+	fstest.MapFS
+	read int
+}
+
+func (f *byteCountingFS) Open(name string) (fs.File, error) {
+	file, err := f.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &byteCountingFile{File: file, fsys: f}, nil
+}
+
+type byteCountingFile struct {
+	fs.File
+	fsys *byteCountingFS
+}
+
+func (f *byteCountingFile) Read(b []byte) (int, error) {
+	n, err := f.File.Read(b)
+	f.fsys.read += n
+	return n, err
+}
+
 func TestModifier_Apply(t *testing.T) {
-	// TODO
+	type Case struct {
+		attribute string
+		modifiers []Modifier
+		expected  interface{}
+		read      int
+	}
+
+	cases := []Case{
+		{attribute: "name", expected: "a.txt", read: 0},
+		{
+			attribute: "name",
+			modifiers: []Modifier{{Name: "UPPER"}},
+			expected:  "A.TXT",
+			read:      0,
+		},
+		{attribute: "hash", expected: "2aae6c3", read: 11},
+		// The file's full hash isn't computed, since SHA1 hashes the file
+		// itself.
+		{
+			attribute: "hash",
+			modifiers: []Modifier{{Name: "SHA1", Arguments: []string{"7", "PREFIX", "1"}}},
+			expected:  "27d5482",
+			read:      1,
+		},
+	}
+
+	for _, c := range cases {
+		fsys := &byteCountingFS{MapFS: fstest.MapFS{"a.txt": {Data: []byte("hello world")}}}
+		info, err := fs.Stat(fsys, "a.txt")
+		if err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+
+		q := NewQuery()
+		q.Attributes = []string{c.attribute}
+		if c.modifiers != nil {
+			q.Modifiers[c.attribute] = c.modifiers
+		}
+		results, err := q.applyModifiers(context.Background(), "a.txt",
+			&transform.FSFileInfo{FileInfo: info, FS: fsys, FSPath: "a.txt"}, nil)
+		if err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+		if actual := results[c.attribute]; actual != c.expected {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected, actual)
+		}
+		if fsys.read != c.read {
+			t.Fatalf("\nExpected %v\n     Got %v", c.read, fsys.read)
+		}
+	}
 }
@@ -0,0 +1,104 @@
+package query
+
+import (
+	"sort"
+
+	"github.com/kashav/fsql/tokenizer"
+)
+
+// Costs of evaluating a condition against a single file, by what evaluating
+// it involves. They're only compared with each other, so their values are
+// rough orders of magnitude.
+const (
+	// costStat is the cost of a condition on the file's stat information.
+	costStat = 1
+	// costSyscall is the cost of a condition which needs another system call
+	// (e.g. reading a symlink's target).
+	costSyscall = 2
+	// costHeader is the cost of a condition which reads the start of the file
+	// (e.g. to detect its MIME type).
+	costHeader = 10
+	// costContent is the cost of a condition which reads the whole file.
+	costContent = 100
+	// costSubquery is the cost of a condition which runs a subquery per file.
+	costSubquery = 1000
+)
+
+// attributeCosts holds the cost of testing each attribute. Attributes which
+// aren't listed cost costStat.
+var attributeCosts = map[string]int{
+	"target":    costSyscall,
+	"device":    costSyscall,
+	"fstype":    costSyscall,
+	"mime":      costHeader,
+	"is_text":   costHeader,
+	"is_binary": costHeader,
+	"width":     costHeader,
+	"height":    costHeader,
+	"imgformat": costHeader,
+	"hash":      costContent,
+	"content":   costContent,
+	"lines":     costContent,
+	"words":     costContent,
+	"chars":     costContent,
+}
+
+// cost returns the estimated cost of evaluating this condition against a
+// single file.
+func (c *Condition) cost() int {
+	if c.IsSubquery && (c.Subquery == nil || c.correlated()) {
+		return costSubquery
+	}
+	if cost, ok := attributeCosts[c.Attribute]; ok {
+		return cost
+	}
+	return costStat
+}
+
+// cost returns the estimated cost of evaluating the tree rooted at root
+// against a single file, i.e. the cost of evaluating every condition.
+func (root *ConditionNode) cost() int {
+	if root == nil {
+		return 0
+	}
+	if root.Condition != nil {
+		return root.Condition.cost()
+	}
+	return root.Left.cost() + root.Right.cost()
+}
+
+// optimize returns the tree rooted at root with the operands of each group of
+// ANDs (or ORs) ordered by their cost, so that evaluating a file stops before
+// the expensive operands (e.g. hashing the file) whenever a cheaper operand
+// decides the result. Operands of the same cost keep the order they were
+// written in. The tree rooted at root isn't modified.
+func (root *ConditionNode) optimize() *ConditionNode {
+	if root == nil || root.Condition != nil {
+		return root
+	}
+
+	operands := root.group(*root.Type)
+	costs := make(map[*ConditionNode]int, len(operands))
+	for i, operand := range operands {
+		operands[i] = operand.optimize()
+		costs[operands[i]] = operands[i].cost()
+	}
+	sort.SliceStable(operands, func(i, j int) bool {
+		return costs[operands[i]] < costs[operands[j]]
+	})
+
+	node := operands[0]
+	for _, operand := range operands[1:] {
+		node = &ConditionNode{Type: root.Type, Left: node, Right: operand}
+	}
+	return node
+}
+
+// group returns the operands of the group of t nodes rooted at root, in the
+// order they're written, e.g. [a, b, c] for `a AND b AND c`.
+func (root *ConditionNode) group(t tokenizer.TokenType) []*ConditionNode {
+	if root.Condition != nil || *root.Type != t {
+		return []*ConditionNode{root}
+	}
+	return append(root.Left.group(t), root.Right.group(t)...)
+}
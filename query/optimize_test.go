@@ -0,0 +1,71 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kashav/fsql/tokenizer"
+)
+
+func TestOptimize_Reorders(t *testing.T) {
+	type Case struct {
+		input    *ConditionNode
+		expected *ConditionNode
+	}
+
+	var (
+		and = tokenizer.And
+		or  = tokenizer.Or
+	)
+
+	leaf := func(attribute string) *ConditionNode {
+		return &ConditionNode{Condition: &Condition{
+			Attribute: attribute,
+			Operator:  tokenizer.Equals,
+			Value:     "x",
+		}}
+	}
+	node := func(t *tokenizer.TokenType, left, right *ConditionNode) *ConditionNode {
+		return &ConditionNode{Type: t, Left: left, Right: right}
+	}
+
+	var (
+		name    = leaf("name")
+		size    = leaf("size")
+		mime    = leaf("mime")
+		hash    = leaf("hash")
+		content = leaf("content")
+		sub     = &ConditionNode{Condition: &Condition{
+			Attribute:  "name",
+			Operator:   tokenizer.In,
+			Value:      "SELECT name FROM . AS x",
+			IsSubquery: true,
+		}}
+	)
+
+	cases := []Case{
+		{input: nil, expected: nil},
+		{input: hash, expected: hash},
+		{input: node(&and, hash, name), expected: node(&and, name, hash)},
+		{input: node(&or, content, size), expected: node(&or, size, content)},
+		// Operands of the same cost keep their order.
+		{input: node(&and, size, name), expected: node(&and, size, name)},
+		// A chain of ANDs is reordered as a whole.
+		{
+			input:    node(&and, node(&and, hash, sub), node(&and, mime, name)),
+			expected: node(&and, node(&and, node(&and, name, mime), hash), sub),
+		},
+		// Nested groups are reordered by their total cost.
+		{
+			input:    node(&and, node(&or, hash, name), node(&or, mime, size)),
+			expected: node(&and, node(&or, size, mime), node(&or, name, hash)),
+		},
+	}
+
+	for _, c := range cases {
+		actual := c.input.optimize()
+		if !reflect.DeepEqual(c.expected, actual) {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package query
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/kashav/fsql/transform"
+)
+
+// Options holds the settings used while executing a query. A nil *Options is
+// equivalent to DefaultOptions().
+type Options struct {
+	// Jobs is the maximum number of files evaluated concurrently. Values less
+	// than 2 evaluate each file serially, inside the walk.
+	Jobs int
+
+	// Walkers is the maximum number of directories read concurrently. Values
+	// less than 2 read each directory serially.
+	Walkers int
+
+	// Unordered passes files to the work function as soon as they're found,
+	// rather than in walk order, which may be faster when Walkers > 1.
+	Unordered bool
+
+	// IgnoreVCS excludes the files ignored by git (through .gitignore files and
+	// the like) from every source, as if each used `USING GITIGNORE`.
+	IgnoreVCS bool
+
+	// MaxDepth and MinDepth limit the depth of the files visited in each
+	// source, whose root is at depth 0, unless overridden by the source's
+	// options. Directories at MaxDepth aren't descended into. A MaxDepth of 0
+	// means there's no limit.
+	MaxDepth, MinDepth int
+
+	// FollowLinks descends into symbolic links to directories in every
+	// source, as if each used `FOLLOW LINKS`.
+	FollowLinks bool
+
+	// OneFilesystem doesn't descend into directories on a different device
+	// than their source's root, in every source, as if each used `ONE
+	// FILESYSTEM`.
+	OneFilesystem bool
+
+	// MaxContentSize is the maximum number of bytes read from each file when
+	// searching or counting its contents. Use a negative value to read entire
+	// files. 0 means transform.DefaultMaxContentSize.
+	MaxContentSize int64
+
+	// Binary searches the contents of binary files, which otherwise never
+	// match content conditions.
+	Binary bool
+
+	// HashCache is the on-disk cache which hashes are read from and written
+	// to. If nil, hashes aren't cached.
+	HashCache *transform.HashCache
+
+	// OnError determines how errors reading files are handled. By default, the
+	// query stops at the first error.
+	OnError ErrorPolicy
+
+	// Timeout limits the time taken by each query (including its subqueries),
+	// after which the query stops with partial results. A Timeout of 0 means
+	// there's no limit. Only applied by fsql.RunContext and the like.
+	Timeout time.Duration
+
+	// Stats is called with the statistics of each query once it's finished
+	// (and its results were printed). If nil, statistics aren't collected.
+	// Only called by fsql.RunContext and the like, otherwise the statistics
+	// of each execution are returned by Query.Stats.
+	Stats func(*Stats)
+
+	// Warn is called with each problem which doesn't stop the query (e.g. a
+	// filesystem loop). If nil, warnings are printed to stderr.
+	Warn func(error)
+
+	// FS is the filesystem that sources are read from, in which case sources
+	// are slash-separated paths in FS (use `.` for its root). If nil, sources
+	// are read from the OS filesystem, through transform.DirFS.
+	FS fs.FS
+}
+
+// DefaultOptions returns the default set of Options.
+func DefaultOptions() *Options {
+	return &Options{Jobs: runtime.NumCPU(), Walkers: runtime.NumCPU()}
+}
+
+// options returns the Options for this query, falling back to the defaults
+// if none were provided.
+func (q *Query) options() *Options {
+	if q.Options == nil {
+		return DefaultOptions()
+	}
+	return q.Options
+}
+
+// contentOptions returns the options used when reading the contents of
+// files.
+func (o *Options) contentOptions() transform.ContentOptions {
+	opts := transform.ContentOptions{MaxSize: o.MaxContentSize, Binary: o.Binary}
+	if opts.MaxSize == 0 {
+		opts.MaxSize = transform.DefaultMaxContentSize
+	}
+	return opts
+}
+
+// warn reports err, which doesn't stop the query.
+func (o *Options) warn(err error) {
+	if o.Warn != nil {
+		o.Warn(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "fsql: %v\n", err)
+}
+
+// SourceOptions holds the settings of a single FROM source.
+type SourceOptions struct {
+	// Archive forces the source to be read as an archive, regardless of its
+	// extension (i.e. `FROM ARCHIVE(path)`).
+	Archive bool
+
+	// GitIgnore excludes the files ignored by git (i.e. `USING GITIGNORE`).
+	GitIgnore bool
+
+	// FollowLinks descends into symbolic links to directories (i.e. `FOLLOW
+	// LINKS`).
+	FollowLinks bool
+
+	// OneFilesystem doesn't descend into directories on a different device
+	// than the source's root (i.e. `ONE FILESYSTEM`).
+	OneFilesystem bool
+
+	// MaxDepth and MinDepth limit the depth of the files visited in this
+	// source (i.e. `MAXDEPTH n` and `MINDEPTH n`), overriding Options.MaxDepth
+	// and Options.MinDepth. 0 means the option isn't set.
+	MaxDepth, MinDepth int
+}
+
+// sourceOptions returns the SourceOptions for src, or the zero value if none
+// were provided.
+func (q *Query) sourceOptions(src string) SourceOptions {
+	if opts, ok := q.SourceOptions[src]; ok && opts != nil {
+		return *opts
+	}
+	return SourceOptions{}
+}
+
+// depthLimits bounds the depth of the files visited in a source, relative to
+// the source's root (which is at depth 0).
+type depthLimits struct {
+	root     string
+	min, max int
+}
+
+// depthLimits returns the depth limits of the source s, which has the options
+// opts.
+func (q *Query) depthLimits(s *source, opts SourceOptions) depthLimits {
+	limits := depthLimits{
+		root: s.path,
+		min:  q.options().MinDepth,
+		max:  q.options().MaxDepth,
+	}
+	if opts.MinDepth > 0 {
+		limits.min = opts.MinDepth
+	}
+	if opts.MaxDepth > 0 {
+		limits.max = opts.MaxDepth
+	}
+	return limits
+}
+
+// depth returns the depth of the file located at path, or 0 if there are no
+// limits (in which case the depth doesn't matter).
+func (d depthLimits) depth(path string) int {
+	if d.min == 0 && d.max == 0 {
+		return 0
+	}
+	rel, err := filepath.Rel(d.root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
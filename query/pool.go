@@ -0,0 +1,127 @@
+package query
+
+import (
+	"context"
+	"os"
+	"sync"
+)
+
+// task represents a single file submitted to a pool.
+type task struct {
+	path string
+	info os.FileInfo
+
+	ok      bool
+	results map[string]interface{}
+	err     error
+	done    chan struct{}
+}
+
+// pool evaluates files with a bounded number of workers. Results are passed to
+// workFunc in the order in which files were submitted, so output is
+// deterministic regardless of the number of workers.
+type pool struct {
+	ctx      context.Context
+	q        *Query
+	workFunc func(string, os.FileInfo, map[string]interface{})
+
+	tasks   chan *task
+	pending chan *task
+	workers sync.WaitGroup
+	done    chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// newPool starts a pool with the provided number of workers, which stop
+// reading files once ctx is done.
+func newPool(ctx context.Context, q *Query, jobs int,
+	workFunc func(string, os.FileInfo, map[string]interface{})) *pool {
+	p := &pool{
+		ctx:      ctx,
+		q:        q,
+		workFunc: workFunc,
+		tasks:    make(chan *task),
+		// Bound the number of files that may be evaluated ahead of the oldest
+		// unfinished file, this keeps memory usage constant on large trees.
+		pending: make(chan *task, 4*jobs),
+		done:    make(chan struct{}),
+	}
+
+	p.workers.Add(jobs)
+	for i := 0; i < jobs; i++ {
+		go p.work()
+	}
+	go p.collect()
+	return p
+}
+
+// submit queues the file at path for evaluation. Returns the first error
+// encountered by the pool, if any.
+func (p *pool) submit(path string, info os.FileInfo) error {
+	if err := p.error(); err != nil {
+		return err
+	}
+	t := &task{path: path, info: info, done: make(chan struct{})}
+	p.pending <- t
+	p.tasks <- t
+	return nil
+}
+
+// wait blocks until every submitted file has been evaluated and returns the
+// first error encountered by the pool, if any.
+func (p *pool) wait() error {
+	close(p.tasks)
+	p.workers.Wait()
+	close(p.pending)
+	<-p.done
+	return p.error()
+}
+
+// work evaluates tasks until the tasks channel is closed.
+func (p *pool) work() {
+	defer p.workers.Done()
+	for t := range p.tasks {
+		if p.error() == nil {
+			t.ok, t.results, t.err = p.q.evaluate(p.ctx, t.path, t.info)
+			if t.err != nil {
+				t.ok, t.err = false, p.q.tolerate(t.err)
+			}
+		}
+		close(t.done)
+	}
+}
+
+// collect passes the result of each task to workFunc, in submission order.
+func (p *pool) collect() {
+	defer close(p.done)
+	for t := range p.pending {
+		<-t.done
+		if t.err != nil {
+			p.setError(t.err)
+		}
+		// Files evaluated ahead of an interruption are dropped, as they would
+		// be by a serial walk.
+		if err := p.ctx.Err(); err != nil {
+			p.setError(err)
+		}
+		if t.ok && p.error() == nil {
+			p.workFunc(t.path, t.info, t.results)
+		}
+	}
+}
+
+func (p *pool) error() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func (p *pool) setError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+}
@@ -0,0 +1,217 @@
+package query
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/kashav/fsql/tokenizer"
+)
+
+// prunes reports whether no file inside the directory located at dir can
+// satisfy the condition tree rooted at root, in which case the directory's
+// contents needn't be walked. The directory itself isn't considered.
+//
+// Only conditions on the path attribute (without modifiers) are analysed, so
+// this is conservative: false is returned unless the result is certain.
+func (root *ConditionNode) prunes(dir string) bool {
+	// Every path inside dir starts with the path of a (hypothetical) child,
+	// without the child's name.
+	prefix := filepath.Join(dir, "x")
+	prefix = prefix[:len(prefix)-1]
+
+	result, ok := root.decide(prefix)
+	return ok && !result
+}
+
+// pruning returns the conditions of the tree rooted at root which may prune
+// directories, i.e. the conditions whose result can be decided for every path
+// inside a directory, and which decide the result of the tree when they do.
+func (root *ConditionNode) pruning() []*Condition {
+	if root == nil {
+		return nil
+	}
+
+	if root.Condition != nil {
+		if root.Condition.prunable() {
+			return []*Condition{root.Condition}
+		}
+		return nil
+	}
+
+	left, right := root.Left.pruning(), root.Right.pruning()
+	if *root.Type == tokenizer.Or && (len(left) == 0 || len(right) == 0) {
+		// Both operands of an OR have to be decided to prune a directory.
+		return nil
+	}
+	return append(left, right...)
+}
+
+// decide returns the result of the tree rooted at root for every path with
+// the given prefix. ok is false if the result may differ between paths.
+func (root *ConditionNode) decide(prefix string) (result, ok bool) {
+	if root == nil {
+		return true, true
+	}
+
+	if root.Condition != nil {
+		return root.Condition.decide(prefix)
+	}
+
+	left, leftOK := root.Left.decide(prefix)
+	right, rightOK := root.Right.decide(prefix)
+	switch *root.Type {
+	case tokenizer.And:
+		if (leftOK && !left) || (rightOK && !right) {
+			return false, true
+		}
+		return true, leftOK && rightOK
+	case tokenizer.Or:
+		if (leftOK && left) || (rightOK && right) {
+			return true, true
+		}
+		return false, leftOK && rightOK
+	}
+	return false, false
+}
+
+// decide returns the result of this condition for every path with the given
+// prefix. ok is false if the result may differ between paths, or if the
+// condition doesn't test the path attribute.
+func (c *Condition) decide(prefix string) (result, ok bool) {
+	if !c.prunable() {
+		return false, false
+	}
+
+	switch c.Operator {
+	case tokenizer.Equals, tokenizer.NotEquals, tokenizer.In:
+		values, isList := conditionValues(c.Operator, c.Value)
+		if !isList {
+			return false, false
+		}
+		// A path can only equal a value that starts with the prefix.
+		for _, value := range values {
+			if strings.HasPrefix(value, prefix) {
+				return false, false
+			}
+		}
+		result, ok = c.Operator == tokenizer.NotEquals, true
+	case tokenizer.Contains:
+		value, isString := c.Value.(string)
+		if !isString || !strings.Contains(prefix, value) {
+			return false, false
+		}
+		result, ok = true, true
+	case tokenizer.Like, tokenizer.ILike:
+		value, isString := c.Value.(string)
+		if !isString {
+			return false, false
+		}
+		if c.Operator == tokenizer.ILike {
+			prefix, value = strings.ToLower(prefix), strings.ToLower(value)
+		}
+		result, ok = decideLike(prefix, value, c.Escape)
+	case tokenizer.Glob:
+		value, isString := c.Value.(string)
+		if !isString {
+			return false, false
+		}
+		// Only the literal start of the pattern is known to rule out a path.
+		lead := value
+		if i := strings.IndexAny(value, `*?[\`); i >= 0 {
+			lead = value[:i]
+		}
+		if strings.HasPrefix(prefix, lead) || strings.HasPrefix(lead, prefix) {
+			return false, false
+		}
+		result, ok = false, true
+	}
+
+	if ok && c.Negate {
+		result = !result
+	}
+	return result, ok
+}
+
+// prunable reports whether this condition's result may be decided for every
+// path with a given prefix (see decide).
+func (c *Condition) prunable() bool {
+	if c.Attribute != "path" || len(c.AttributeModifiers) > 0 || c.IsSubquery {
+		return false
+	}
+	switch c.Operator {
+	case tokenizer.Equals, tokenizer.NotEquals, tokenizer.In, tokenizer.Contains,
+		tokenizer.Like, tokenizer.ILike, tokenizer.Glob:
+		return true
+	}
+	return false
+}
+
+// decideLike returns the result of `path LIKE pattern ESCAPE escape` for every
+// path with the given prefix (see likeToRegexp for the semantics of LIKE).
+func decideLike(prefix, pattern string, escape rune) (result, ok bool) {
+	lead, rest := likeLiteral(pattern, escape)
+	switch {
+	case rest == "":
+		// A pattern without wildcards only matches the path itself, which must
+		// start with the prefix.
+		return false, !strings.HasPrefix(lead, prefix)
+	case rest == "%":
+		if strings.HasPrefix(prefix, lead) {
+			return true, true
+		}
+		return false, !strings.HasPrefix(lead, prefix)
+	case lead == "" && strings.HasPrefix(rest, "%") && strings.HasSuffix(rest, "%"):
+		middle, end := likeLiteral(rest[1:], escape)
+		if end == "%" && middle != "" {
+			return true, strings.Contains(prefix, middle)
+		}
+	case lead != "" && !strings.HasPrefix(prefix, lead) && !strings.HasPrefix(lead, prefix):
+		// The suffix of a path is never known in advance, but no path with
+		// the prefix can start with lead.
+		return false, true
+	}
+	return false, false
+}
+
+// likeLiteral splits the LIKE pattern at its first wildcard, returning the
+// literal text before it (without escape characters) and the rest of the
+// pattern, starting with the wildcard.
+func likeLiteral(pattern string, escape rune) (literal, rest string) {
+	var b strings.Builder
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case escape != 0 && r == escape && i+1 < len(runes):
+			i++
+			b.WriteRune(runes[i])
+		case r == '%' || r == '_':
+			return b.String(), string(runes[i:])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), ""
+}
+
+// conditionValues returns the values that a path is compared against with
+// the operator op. Returns false if value isn't a string or list of strings.
+func conditionValues(op tokenizer.TokenType, value interface{}) ([]string, bool) {
+	switch v := value.(type) {
+	case string:
+		if op == tokenizer.In {
+			return strings.Split(v, ","), true
+		}
+		return []string{v}, true
+	case []string:
+		return v, true
+	case map[interface{}]bool:
+		values := make([]string, 0, len(v))
+		for key := range v {
+			if s, ok := key.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values, true
+	}
+	return nil, false
+}
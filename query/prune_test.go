@@ -0,0 +1,172 @@
+package query
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kashav/fsql/tokenizer"
+)
+
+func TestPrune_Prunes(t *testing.T) {
+	type Case struct {
+		tree     *ConditionNode
+		dir      string
+		expected bool
+	}
+
+	var (
+		and = tokenizer.And
+		or  = tokenizer.Or
+	)
+
+	leaf := func(attribute string, op tokenizer.TokenType, value interface{},
+		negate bool) *ConditionNode {
+		return &ConditionNode{Condition: &Condition{
+			Attribute: attribute,
+			Operator:  op,
+			Value:     value,
+			Negate:    negate,
+		}}
+	}
+	notVendor := leaf("path", tokenizer.Like, "%/vendor/%", true)
+	inSrc := leaf("path", tokenizer.Like, "src/%", false)
+
+	cases := []Case{
+		{tree: nil, dir: "a", expected: false},
+		{tree: notVendor, dir: "a/vendor", expected: true},
+		{tree: notVendor, dir: "a/vendor/b", expected: true},
+		{tree: notVendor, dir: "a/vendored", expected: false},
+		{tree: notVendor, dir: "vendor", expected: false},
+		{tree: inSrc, dir: "docs", expected: true},
+		{tree: inSrc, dir: "src", expected: false},
+		{tree: inSrc, dir: ".", expected: false},
+		{tree: leaf("path", tokenizer.Like, "%.go", false), dir: "docs", expected: false},
+		{tree: leaf("path", tokenizer.Like, "src/%.go", false), dir: "docs", expected: true},
+		{tree: leaf("path", tokenizer.Like, "src/%.go", false), dir: "src/a", expected: false},
+		{tree: leaf("path", tokenizer.Like, "s_c/%", false), dir: "sxc", expected: false},
+		{tree: leaf("path", tokenizer.Like, "s_c/%", false), dir: "docs", expected: true},
+		{tree: leaf("path", tokenizer.Like, "src/main.go", false), dir: "src", expected: false},
+		{tree: leaf("path", tokenizer.Like, "src/main.go", false), dir: "src/main.go", expected: true},
+		{tree: leaf("path", tokenizer.Like, "src", false), dir: "src/a", expected: true},
+		{tree: leaf("path", tokenizer.Like, "src/main.go", false), dir: "docs/src/main.go", expected: true},
+		{tree: leaf("path", tokenizer.Like, "%/vendor%/%", true), dir: "a/vendor", expected: false},
+		{tree: leaf("path", tokenizer.ILike, "SRC/%", false), dir: "docs", expected: true},
+		{tree: leaf("path", tokenizer.ILike, "SRC/%", false), dir: "src", expected: false},
+		{
+			tree: &ConditionNode{Condition: &Condition{
+				Attribute: "path",
+				Operator:  tokenizer.Like,
+				Value:     "100!%/%",
+				Escape:    '!',
+			}},
+			dir:      "100%",
+			expected: false,
+		},
+		{
+			tree: &ConditionNode{Condition: &Condition{
+				Attribute: "path",
+				Operator:  tokenizer.Like,
+				Value:     "100!%/%",
+				Escape:    '!',
+			}},
+			dir:      "100",
+			expected: true,
+		},
+		{tree: leaf("path", tokenizer.Glob, "src/**/*.go", false), dir: "docs", expected: true},
+		{tree: leaf("path", tokenizer.Glob, "src/**/*.go", false), dir: "src/a", expected: false},
+		{tree: leaf("path", tokenizer.Glob, "*/vendor/*", false), dir: "docs", expected: false},
+		{tree: leaf("path", tokenizer.Contains, "node_modules", true), dir: "a/node_modules", expected: true},
+		{tree: leaf("path", tokenizer.Equals, "a/b", false), dir: "c", expected: true},
+		{tree: leaf("path", tokenizer.Equals, "a/b", false), dir: "a", expected: false},
+		{tree: leaf("path", tokenizer.NotEquals, "a/b", false), dir: "c", expected: false},
+		{tree: leaf("path", tokenizer.In, []string{"a/b", "c/d"}, false), dir: "e", expected: true},
+		{tree: leaf("path", tokenizer.In, []string{"a/b", "c/d"}, false), dir: "c", expected: false},
+		{tree: leaf("path", tokenizer.RLike, "^src/", false), dir: "docs", expected: false},
+		{tree: leaf("name", tokenizer.Equals, "a/b", false), dir: "c", expected: false},
+		{
+			tree: &ConditionNode{
+				Condition: &Condition{
+					Attribute:          "path",
+					AttributeModifiers: []Modifier{{Name: "UPPER"}},
+					Operator:           tokenizer.Like,
+					Value:              "SRC/%",
+				},
+			},
+			dir:      "docs",
+			expected: false,
+		},
+		{
+			tree:     &ConditionNode{Type: &and, Left: leaf("size", tokenizer.GreaterThan, "10", false), Right: inSrc},
+			dir:      "docs",
+			expected: true,
+		},
+		{
+			tree:     &ConditionNode{Type: &or, Left: inSrc, Right: leaf("name", tokenizer.Equals, "main.go", false)},
+			dir:      "docs",
+			expected: false,
+		},
+		{
+			tree:     &ConditionNode{Type: &or, Left: inSrc, Right: notVendor},
+			dir:      "docs/vendor",
+			expected: true,
+		},
+	}
+
+	for _, c := range cases {
+		if actual := c.tree.prunes(filepath.FromSlash(c.dir)); actual != c.expected {
+			t.Fatalf("%v, %s\nExpected %v\n     Got %v", c.tree, c.dir, c.expected, actual)
+		}
+	}
+}
+
+func TestPrune_Execute(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/vendor/x/y.go": {},
+		"a/z.go":          {},
+		"b/vendor/y.go":   {},
+		"c/y.go":          {},
+	}
+
+	q := NewQuery()
+	q.Sources["include"] = []string{"."}
+	q.Sources["exclude"] = []string{"c"}
+	q.ConditionTree = &ConditionNode{Condition: &Condition{
+		Attribute: "path",
+		Operator:  tokenizer.Like,
+		Value:     "%/vendor/%",
+		Negate:    true,
+	}}
+	q.Options = &Options{Jobs: 1, Walkers: 1, FS: fsys}
+
+	visited := map[string]bool{}
+	walkFn := q.walkFunc(context.Background(), map[string]bool{},
+		&regexpExclude{exclusions: q.Sources["exclude"]}, q.ConditionTree, nil,
+		depthLimits{root: "."}, func(path string, info os.FileInfo) error {
+			visited[path] = true
+			return nil
+		})
+	w := newWalker(q.options(), func(path string, info os.FileInfo, err error) error {
+		if info != nil && info.IsDir() {
+			// Record each directory which is read.
+			visited[path+"/"] = true
+		}
+		return walkFn(path, info, err)
+	})
+	if err := w.walk(&source{fsys: fsys, root: ".", path: "."}); err != nil {
+		t.Fatalf("\nExpected no error\n     Got %v", err)
+	}
+
+	for _, path := range []string{"a/vendor/x/", "a/vendor/x", "c/y.go"} {
+		if visited[path] {
+			t.Fatalf("\nExpected %s to be pruned\n     Got %v", path, visited)
+		}
+	}
+	for _, path := range []string{"a/vendor", "a/z.go", "b/vendor"} {
+		if !visited[path] {
+			t.Fatalf("\nExpected %s to be visited\n     Got %v", path, visited)
+		}
+	}
+}
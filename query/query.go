@@ -1,9 +1,15 @@
 package query
 
 import (
+	"context"
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"strings"
+	"sync"
+	"time"
+
+	"github.com/kashav/fsql/transform"
 )
 
 // Query represents an input query.
@@ -13,8 +19,28 @@ type Query struct {
 
 	Sources       map[string][]string
 	SourceAliases map[string]string
+	SourceOptions map[string]*SourceOptions
 
 	ConditionTree *ConditionNode
+
+	Options *Options
+
+	// Explain is set for `EXPLAIN SELECT ...`, in which case the query's Plan
+	// is printed rather than executing it (and its subqueries aren't run).
+	Explain bool
+
+	duplicates map[string]duplicate
+	archives   map[string]*archive
+
+	// skipped holds the errors skipped while executing the query, as per
+	// Options.OnError.
+	skipped   []error
+	skippedMu sync.Mutex
+
+	// stats collects the statistics of the current execution, if requested
+	// through Options.Stats, and lastStats holds those of the last one.
+	stats     *statistics
+	lastStats *Stats
 }
 
 // NewQuery returns a pointer to a Query.
@@ -27,6 +53,7 @@ func NewQuery() *Query {
 			"exclude": make([]string, 0),
 		},
 		SourceAliases: make(map[string]string),
+		SourceOptions: make(map[string]*SourceOptions),
 		ConditionTree: nil,
 	}
 }
@@ -46,33 +73,145 @@ func (q *Query) HasAttribute(attributes ...string) bool {
 // Execute runs the query by walking the full path of each source and
 // evaluating the condition tree for each file. This method calls workFunc on
 // each "successful" file.
+//
+// The operands of each AND and OR in the condition tree are reordered so that
+// cheap conditions (e.g. on the file's name) are evaluated before expensive
+// ones (e.g. on its hash or content).
+//
+// Files are evaluated by up to Options.Jobs workers, but workFunc is always
+// called from a single goroutine, in walk order.
+//
+// If files were skipped due to errors (see Options.OnError), an
+// *ErrPartialResults is returned once the query has finished.
 func (q *Query) Execute(workFunc interface{}) error {
+	return q.ExecuteContext(context.Background(), workFunc)
+}
+
+// ExecuteContext is Execute, which stops once ctx is done. If the query is
+// interrupted, the files which were already passed to workFunc are partial
+// results, so an *ErrPartialResults wrapping ctx.Err() is returned.
+func (q *Query) ExecuteContext(ctx context.Context, workFunc interface{}) (err error) {
+	fn := workFunc.(func(string, os.FileInfo, map[string]interface{}))
+	defer q.closeArchives()
+
+	q.skipped = nil
+	defer func() {
+		err = q.partialResults(ctx, err)
+	}()
+
+	if err := q.ConditionTree.prepare(q.options().FS); err != nil {
+		return err
+	}
+	if err := q.prepareModifiers(); err != nil {
+		return err
+	}
+	q.ConditionTree = q.ConditionTree.optimize()
+
+	ctx = transform.WithContentOptions(ctx, q.options().contentOptions())
+	if cache := q.options().HashCache; cache != nil {
+		ctx = transform.WithHashCache(ctx, cache)
+	}
+	q.stats, q.lastStats = nil, nil
+	if q.options().Stats != nil {
+		q.stats = newStatistics(q.ConditionTree)
+		ctx = transform.WithReadCounter(ctx, &q.stats.bytesRead)
+		start := time.Now()
+		defer func() {
+			q.lastStats = q.stats.stats(time.Since(start), len(q.skipped))
+		}()
+	}
+
+	if q.HasAttribute(duplicateAttributes...) ||
+		q.ConditionTree.hasAttribute(duplicateAttributes...) {
+		if err := q.findDuplicates(ctx); err != nil {
+			return err
+		}
+	}
+
+	visit := func(path string, info os.FileInfo) error {
+		defer q.stats.timer(visitDuration)()
+		ok, results, err := q.evaluate(ctx, path, info)
+		if err != nil || !ok {
+			return q.tolerate(err)
+		}
+		fn(path, info, results)
+		return nil
+	}
+
+	if jobs := q.options().Jobs; jobs > 1 {
+		p := newPool(ctx, q, jobs, fn)
+		defer func() {
+			if waitErr := p.wait(); err == nil {
+				err = waitErr
+			}
+		}()
+		visit = func(path string, info os.FileInfo) error {
+			defer q.stats.timer(visitDuration)()
+			return p.submit(path, info)
+		}
+	}
+
+	defer q.stats.timer(walkDuration)()
+	return q.walk(ctx, visit, q.ConditionTree)
+}
+
+// walk walks the full path of each source and calls visit on each file that
+// isn't excluded (or ignored by git, if requested). Each file is visited at
+// most once. Directories which can't contain any file matching the condition
+// tree rooted at prune (if non-nil) aren't descended into. The walk stops
+// once ctx is done.
+func (q *Query) walk(ctx context.Context, visit func(string, os.FileInfo) error,
+	prune *ConditionNode) error {
 	seen := map[string]bool{}
-	excluder := &regexpExclude{exclusions: q.Sources["exclude"]}
+	excluder, err := q.excluder()
+	if err != nil {
+		return err
+	}
+
+	// The global excludes file is read at most once per walk.
+	var global globRules
+	readGlobal := false
 
 	for _, src := range q.Sources["include"] {
-		// TODO: Improve our method of detecting if src is a glob pattern. This
-		// currently doesn't support usage of square brackets, since the tokenizer
-		// doesn't recognize these as part of a directory.
-		//
-		// Pattern reference: https://golang.org/pkg/path/filepath/#Match.
-		if strings.ContainsAny(src, "*?") {
-			// If src does _resemble_ a glob pattern, we find all matches and
-			// evaluate the condition tree against each.
-			matches, err := filepath.Glob(src)
-			if err != nil {
-				return err
+		opts := q.sourceOptions(src)
+
+		// A glob pattern is applied as a filter while walking its base.
+		path := src
+		var g *glob
+		if isGlob(src) {
+			g = newGlob(src)
+			path = g.base
+		}
+
+		s, err := q.newSource(path, opts)
+		if err != nil {
+			return err
+		}
+		if g != nil {
+			// As with a pattern without any matches, a missing base matches
+			// nothing.
+			if _, err := fs.Stat(s.fsys, s.root); errors.Is(err, fs.ErrNotExist) {
+				continue
 			}
+		}
 
-			for _, match := range matches {
-				if err = filepath.Walk(match, q.walkFunc(seen, excluder, workFunc)); err != nil {
-					return err
-				}
+		exclude := excluder
+		if g, ok := excluder.(*globExclude); ok {
+			exclude = g.forSource(s.path)
+		}
+		if opts.GitIgnore || q.options().IgnoreVCS {
+			if !readGlobal {
+				global, readGlobal = globalExcludes(), true
 			}
-			continue
+			exclude = excluders{excluder, q.newGitIgnore(s, global)}
 		}
 
-		if err := filepath.Walk(src, q.walkFunc(seen, excluder, workFunc)); err != nil {
+		limits := q.depthLimits(s, opts)
+		w := newWalker(q.options(), q.walkFunc(ctx, seen, exclude, prune, g, limits, visit))
+		w.descends = q.descends(ctx, seen, exclude, prune, g, limits)
+		w.followLinks = w.followLinks || opts.FollowLinks
+		w.oneFilesystem = w.oneFilesystem || opts.OneFilesystem
+		if err := w.walk(s); err != nil {
 			return err
 		}
 	}
@@ -80,40 +219,143 @@ func (q *Query) Execute(workFunc interface{}) error {
 	return nil
 }
 
-// walkFunc returns a filepath.WalkFunc which evaluates the condition tree
-// against the given file.
-func (q *Query) walkFunc(seen map[string]bool, excluder Excluder,
-	workFunc interface{}) filepath.WalkFunc {
+// walkFunc returns a filepath.WalkFunc which passes each file that isn't
+// excluded (and is within limits) to visit. Excluded directories, directories
+// at the maximum depth, and directories pruned by the condition tree rooted
+// at prune, aren't descended into. Once ctx is done, ctx.Err() is returned,
+// which stops the walk.
+//
+// If g is non-nil, only the files matching g (and their contents) are visited,
+// and only directories which may contain such files are descended into. The
+// depth of each file is relative to the file matching g that contains it.
+func (q *Query) walkFunc(ctx context.Context, seen map[string]bool,
+	excluder Excluder, prune *ConditionNode, g *glob, limits depthLimits,
+	visit func(string, os.FileInfo) error) filepath.WalkFunc {
 	return func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		if err != nil {
+			if err = q.tolerate(err); err == nil && info != nil && info.IsDir() {
+				// The directory couldn't be read, so it's skipped.
+				return filepath.SkipDir
+			}
 			return err
 		}
 
-		if path == "." {
-			return nil
-		}
-
 		// Avoid walking a single directory more than once.
 		if _, ok := seen[path]; ok {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		seen[path] = true
 
-		if excluder.shouldExclude(path) {
+		if excluder.shouldExclude(path, info.IsDir()) {
+			q.stats.excludedFile()
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
-		if ok, err := q.ConditionTree.evaluateTree(path, info); err != nil {
-			return err
-		} else if !ok {
-			return nil
+		limits := limits
+		if g != nil {
+			root, ok := g.match(path)
+			if !ok {
+				if info.IsDir() && !g.mayContain(path) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			limits.root = root
 		}
 
-		results, err := q.applyModifiers(path, info)
-		if err != nil {
-			return err
+		depth := limits.depth(path)
+		if path != "." && depth >= limits.min {
+			q.stats.visitedFile()
+			if err := visit(path, info); err != nil {
+				return err
+			}
+		}
+
+		if info.IsDir() && limits.max > 0 && depth >= limits.max {
+			return filepath.SkipDir
+		}
+		if prune != nil && info.IsDir() && prune.prunes(path) {
+			q.stats.prunedDir()
+			return filepath.SkipDir
+		}
+		return nil
+	}
+}
+
+// descends returns a function which reports whether the WalkFunc returned by
+// walkFunc (with the same arguments) would descend into a directory, unless
+// visiting it fails. Unlike the WalkFunc, it has no side effects, so that the
+// walker can read directories ahead of time.
+func (q *Query) descends(ctx context.Context, seen map[string]bool,
+	excluder Excluder, prune *ConditionNode, g *glob,
+	limits depthLimits) func(string, os.FileInfo) bool {
+	return func(path string, info os.FileInfo) bool {
+		if ctx.Err() != nil || seen[path] || excluder.shouldExclude(path, true) {
+			return false
+		}
+
+		limits := limits
+		if g != nil {
+			root, ok := g.match(path)
+			if !ok {
+				return g.mayContain(path)
+			}
+			limits.root = root
 		}
-		workFunc.(func(string, os.FileInfo, map[string]interface{}))(path, info, results)
+
+		if limits.max > 0 && limits.depth(path) >= limits.max {
+			return false
+		}
+		return prune == nil || !prune.prunes(path)
+	}
+}
+
+// evaluate evaluates the condition tree against the given file and, if it
+// matches, applies the SELECT modifiers. Safe for concurrent use once the
+// condition tree has been prepared. Reading the file stops once ctx is done.
+func (q *Query) evaluate(ctx context.Context, path string,
+	info os.FileInfo) (bool, map[string]interface{}, error) {
+	computed := q.computedValues(path, info)
+	// The file's hash may be needed by both the WHERE and SELECT clauses.
+	ctx = transform.WithHashMemo(ctx)
+
+	stop := q.stats.timer(evaluateDuration)
+	ok, err := q.ConditionTree.evaluateTree(ctx, path, info, computed, q.stats)
+	stop()
+	if err != nil || !ok {
+		return false, nil, err
+	}
+
+	stop = q.stats.timer(formatDuration)
+	results, err := q.applyModifiers(ctx, path, info, computed)
+	stop()
+	if err != nil {
+		return false, nil, err
+	}
+	q.stats.matchedFile()
+	return true, results, nil
+}
+
+// computedValues returns the values of the attributes of the given file which
+// can't be computed from the file alone (e.g. attributes which depend on
+// other files), keyed by attribute name. Returns nil if there are none.
+func (q *Query) computedValues(path string, info os.FileInfo) map[string]interface{} {
+	if q.duplicates == nil {
 		return nil
 	}
+
+	d := q.duplicates[path]
+	return map[string]interface{}{
+		"dupcount": d.count,
+		"dupgroup": d.group,
+	}
 }
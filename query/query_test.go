@@ -1 +1,36 @@
 package query
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestQuery_ExecuteJobs(t *testing.T) {
+	paths := func(jobs int) []string {
+		q := NewQuery()
+		q.Attributes = []string{"name", "hash"}
+		q.Sources["include"] = []string{"../testdata"}
+		q.Options = &Options{Jobs: jobs}
+
+		result := make([]string, 0)
+		if err := q.Execute(
+			func(path string, info os.FileInfo, _ map[string]interface{}) {
+				result = append(result, path)
+			},
+		); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+		return result
+	}
+
+	expected := paths(1)
+	if len(expected) == 0 {
+		t.Fatalf("\nExpected results\n     Got none")
+	}
+	for _, jobs := range []int{2, 4, 16} {
+		if actual := paths(jobs); !reflect.DeepEqual(expected, actual) {
+			t.Fatalf("\nExpected %v\n     Got %v", expected, actual)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// Stats holds the statistics of an execution of a query, which are collected
+// if Options.Stats is set (see Query.Stats).
+type Stats struct {
+	// Visited is the number of files found by the walk which were evaluated
+	// (i.e. those which weren't excluded, nor filtered out by a glob or the
+	// depth limits). Matched is the number which satisfied the condition tree.
+	// Excluded is the number which were excluded (counting an excluded
+	// directory once, regardless of its contents), and Errored is the number
+	// which were skipped since they couldn't be read.
+	Visited, Matched, Excluded, Errored int64
+
+	// Pruned is the number of directories which weren't descended into, since
+	// no file inside them could satisfy the condition tree.
+	Pruned int64
+
+	// BytesRead is the number of bytes read from files to compute their
+	// attributes, e.g. to hash them, search or count their content, or detect
+	// their type. Hashes read from the cache (if any) aren't counted.
+	BytesRead int64
+
+	// Total is the time taken by the query. Walk is the time spent walking
+	// sources, Evaluate the time spent evaluating the condition tree, and
+	// Format the time spent applying the modifiers of each attribute (and
+	// printing the results, for fsql.Run). If files are evaluated by several
+	// workers, Evaluate and Format are summed across workers.
+	Total, Walk, Evaluate, Format time.Duration
+
+	// Conditions holds the statistics of each condition, in the order they're
+	// evaluated.
+	Conditions []ConditionStats
+}
+
+// ConditionStats holds the statistics of a single condition.
+type ConditionStats struct {
+	// Condition is the condition, as it's written in the query.
+	Condition string
+
+	// Evaluated is the number of files the condition was evaluated against,
+	// and Matched the number of files which satisfied it.
+	Evaluated, Matched int64
+}
+
+// Selectivity returns the fraction of the files this condition was evaluated
+// against which satisfied it, or 0 if it wasn't evaluated.
+func (s ConditionStats) Selectivity() float64 {
+	if s.Evaluated == 0 {
+		return 0
+	}
+	return float64(s.Matched) / float64(s.Evaluated)
+}
+
+func (s *Stats) String() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "files:\t%d visited, %d matched, %d excluded, %d errored\n",
+		s.Visited, s.Matched, s.Excluded, s.Errored)
+	fmt.Fprintf(w, "directories:\t%d pruned\n", s.Pruned)
+	fmt.Fprintf(w, "read:\t%d bytes\n", s.BytesRead)
+	fmt.Fprintf(w, "time:\t%v total, %v walking, %v evaluating, %v formatting\n",
+		round(s.Total), round(s.Walk), round(s.Evaluate), round(s.Format))
+	w.Flush()
+
+	if len(s.Conditions) == 0 {
+		return b.String()
+	}
+	b.WriteString("conditions:\n")
+	w = tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, c := range s.Conditions {
+		fmt.Fprintf(w, "  %s\t%d evaluated, %d matched (%.1f%%)\n", c.Condition,
+			c.Evaluated, c.Matched, 100*c.Selectivity())
+	}
+	w.Flush()
+	return b.String()
+}
+
+// round rounds d for display, keeping at most 3 significant digits.
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	case d >= time.Microsecond:
+		return d.Round(10 * time.Nanosecond)
+	}
+	return d
+}
+
+// statistics collects the Stats of an execution. Its counters are updated
+// atomically, since files may be evaluated concurrently. Its methods may be
+// called on a nil *statistics (i.e. when statistics aren't collected), in
+// which case they do nothing.
+type statistics struct {
+	visited, matched, excluded, pruned int64
+	bytesRead                          int64
+
+	// durations holds each duration, in nanoseconds. The walk duration
+	// includes the visit duration, which is subtracted from it.
+	durations [numDurations]int64
+
+	// conditions holds the counters of each condition of the condition tree,
+	// and order holds the conditions in the order they're evaluated.
+	conditions map[*Condition]*conditionStatistics
+	order      []*Condition
+}
+
+// conditionStatistics collects the ConditionStats of a single condition.
+type conditionStatistics struct {
+	evaluated, matched int64
+}
+
+// newStatistics returns the statistics for an execution of the condition tree
+// rooted at root.
+func newStatistics(root *ConditionNode) *statistics {
+	s := &statistics{conditions: make(map[*Condition]*conditionStatistics)}
+	s.order = root.conditions()
+	for _, c := range s.order {
+		s.conditions[c] = &conditionStatistics{}
+	}
+	return s
+}
+
+func (s *statistics) visitedFile() {
+	if s != nil {
+		atomic.AddInt64(&s.visited, 1)
+	}
+}
+
+func (s *statistics) matchedFile() {
+	if s != nil {
+		atomic.AddInt64(&s.matched, 1)
+	}
+}
+
+func (s *statistics) excludedFile() {
+	if s != nil {
+		atomic.AddInt64(&s.excluded, 1)
+	}
+}
+
+func (s *statistics) prunedDir() {
+	if s != nil {
+		atomic.AddInt64(&s.pruned, 1)
+	}
+}
+
+// duration identifies one of the durations measured by a statistics.
+type duration int
+
+const (
+	walkDuration duration = iota
+	visitDuration
+	evaluateDuration
+	formatDuration
+	numDurations
+)
+
+// timer returns a function which adds the time elapsed until it's called to
+// the duration d, e.g. `defer s.timer(evaluateDuration)()`.
+func (s *statistics) timer(d duration) func() {
+	if s == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		atomic.AddInt64(&s.durations[d], int64(time.Since(start)))
+	}
+}
+
+// load returns the duration d.
+func (s *statistics) load(d duration) time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.durations[d]))
+}
+
+// record records the result of evaluating the condition c.
+func (s *statistics) record(c *Condition, ok bool) {
+	if s == nil {
+		return
+	}
+	if cs, found := s.conditions[c]; found {
+		atomic.AddInt64(&cs.evaluated, 1)
+		if ok {
+			atomic.AddInt64(&cs.matched, 1)
+		}
+	}
+}
+
+// stats returns the Stats collected by s, where total is the time taken by the
+// execution and errored the number of files skipped due to errors.
+func (s *statistics) stats(total time.Duration, errored int) *Stats {
+	stats := &Stats{
+		Visited:   atomic.LoadInt64(&s.visited),
+		Matched:   atomic.LoadInt64(&s.matched),
+		Excluded:  atomic.LoadInt64(&s.excluded),
+		Errored:   int64(errored),
+		Pruned:    atomic.LoadInt64(&s.pruned),
+		BytesRead: atomic.LoadInt64(&s.bytesRead),
+		Total:     total,
+		Walk:      s.load(walkDuration) - s.load(visitDuration),
+		Evaluate:  s.load(evaluateDuration),
+		Format:    s.load(formatDuration),
+	}
+	for _, c := range s.order {
+		cs := s.conditions[c]
+		stats.Conditions = append(stats.Conditions, ConditionStats{
+			Condition: c.String(),
+			Evaluated: atomic.LoadInt64(&cs.evaluated),
+			Matched:   atomic.LoadInt64(&cs.matched),
+		})
+	}
+	return stats
+}
+
+// conditions returns the conditions of the tree rooted at root, in the order
+// they're evaluated.
+func (root *ConditionNode) conditions() []*Condition {
+	if root == nil {
+		return nil
+	}
+	if root.Condition != nil {
+		return []*Condition{root.Condition}
+	}
+	return append(root.Left.conditions(), root.Right.conditions()...)
+}
+
+// Stats returns the statistics of the last execution of this query, or nil if
+// they weren't collected (see Options.Stats).
+func (q *Query) Stats() *Stats {
+	return q.lastStats
+}
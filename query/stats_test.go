@@ -0,0 +1,219 @@
+package query
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kashav/fsql/tokenizer"
+)
+
+func TestStats_Execute(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/x.go":          {Data: []byte("TODO: foo")},
+		"a/y.go":          {Data: []byte("bar")},
+		"a/z.txt":         {Data: []byte("TODO")},
+		"a/vendor/v.go":   {Data: []byte("TODO")},
+		"b/w.go":          {Data: []byte("baz")},
+		"c/excluded.go":   {Data: []byte("TODO")},
+		"a/vendor/u/t.go": {Data: []byte("TODO")},
+	}
+
+	and := tokenizer.And
+	tree := &ConditionNode{
+		Type: &and,
+		Left: &ConditionNode{
+			Type: &and,
+			Left: &ConditionNode{Condition: &Condition{
+				Attribute: "content",
+				Operator:  tokenizer.Contains,
+				Value:     "TODO",
+			}},
+			Right: &ConditionNode{Condition: &Condition{
+				Attribute: "name",
+				Operator:  tokenizer.Like,
+				Value:     "%.go",
+			}},
+		},
+		Right: &ConditionNode{Condition: &Condition{
+			Attribute: "path",
+			Operator:  tokenizer.Like,
+			Value:     "%/vendor/%",
+			Negate:    true,
+		}},
+	}
+
+	type Expected struct {
+		visited, matched, excluded, pruned, bytesRead int64
+		conditions                                    []ConditionStats
+	}
+
+	expected := Expected{
+		// a, a/x.go, a/y.go, a/z.txt, a/vendor, b, b/w.go
+		visited:  7,
+		matched:  1,
+		excluded: 1,
+		pruned:   1,
+		// The content of a/x.go, a/y.go and b/w.go.
+		bytesRead: 15,
+		conditions: []ConditionStats{
+			{Condition: "name LIKE %.go", Evaluated: 7, Matched: 3},
+			{Condition: "NOT path LIKE %/vendor/%", Evaluated: 3, Matched: 3},
+			{Condition: "content CONTAINS TODO", Evaluated: 3, Matched: 1},
+		},
+	}
+
+	for _, jobs := range []int{1, 4} {
+		var reported *Stats
+		q := NewQuery()
+		q.Attributes = []string{"name"}
+		q.Sources["include"] = []string{"."}
+		q.Sources["exclude"] = []string{"c"}
+		q.ConditionTree = tree
+		q.Options = &Options{Jobs: jobs, FS: fsys, Stats: func(s *Stats) { reported = s }}
+
+		if err := q.Execute(func(string, os.FileInfo, map[string]interface{}) {}); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+		if reported != nil {
+			t.Fatalf("\nExpected Options.Stats not to be called\n     Got %v", reported)
+		}
+
+		s := q.Stats()
+		if s == nil {
+			t.Fatalf("\nExpected stats\n     Got nil")
+		}
+		actual := Expected{
+			visited:    s.Visited,
+			matched:    s.Matched,
+			excluded:   s.Excluded,
+			pruned:     s.Pruned,
+			bytesRead:  s.BytesRead,
+			conditions: s.Conditions,
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Fatalf("\nExpected %v\n     Got %v", expected, actual)
+		}
+	}
+
+	// Stats aren't collected unless they're requested.
+	q := NewQuery()
+	q.Sources["include"] = []string{"."}
+	q.Options = &Options{Jobs: 1, FS: fsys}
+	if err := q.Execute(func(string, os.FileInfo, map[string]interface{}) {}); err != nil {
+		t.Fatalf("\nExpected no error\n     Got %v", err)
+	}
+	if s := q.Stats(); s != nil {
+		t.Fatalf("\nExpected no stats\n     Got %v", s)
+	}
+}
+
+func TestStats_Duplicates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"d/a":      {Data: []byte("foo")},
+		"d/b":      {Data: []byte("foo")},
+		"d/c":      {Data: []byte("bar")},
+		"d/sub/x":  {Data: []byte("baz")},
+		"d/skip/y": {Data: []byte("foo")},
+	}
+
+	q := NewQuery()
+	q.Attributes = []string{"name"}
+	q.Sources["include"] = []string{"d"}
+	q.Sources["exclude"] = []string{"d/skip"}
+	q.ConditionTree = &ConditionNode{Condition: &Condition{
+		Attribute: "dupcount",
+		Operator:  tokenizer.GreaterThan,
+		Value:     "1",
+	}}
+	q.Options = &Options{Jobs: 1, FS: fsys, Stats: func(*Stats) {}}
+	if err := q.Execute(func(string, os.FileInfo, map[string]interface{}) {}); err != nil {
+		t.Fatalf("\nExpected no error\n     Got %v", err)
+	}
+
+	// d, d/a, d/b, d/c, d/sub and d/sub/x are each visited once, even though
+	// they're also walked to find duplicates.
+	type Expected struct {
+		visited, matched, excluded int64
+	}
+	expected := Expected{visited: 6, matched: 2, excluded: 1}
+	s := q.Stats()
+	if actual := (Expected{s.Visited, s.Matched, s.Excluded}); actual != expected {
+		t.Fatalf("\nExpected %v\n     Got %v", expected, actual)
+	}
+}
+
+func TestStats_BytesRead(t *testing.T) {
+	fsys := fstest.MapFS{
+		"t/a": {Data: []byte("hello\n")},
+		"t/b": {Data: []byte("world!\n")},
+	}
+
+	type Case struct {
+		attributes []string
+		modifiers  map[string][]Modifier
+		condition  *Condition
+		expected   int64
+	}
+
+	cases := []Case{
+		{attributes: []string{"name"}, expected: 0},
+		{attributes: []string{"name", "hash"}, expected: 13},
+		{
+			attributes: []string{"hash"},
+			condition:  &Condition{Attribute: "hash", Operator: tokenizer.NotEquals, Value: "abc"},
+			expected:   13,
+		},
+		{
+			attributes: []string{"hash"},
+			modifiers:  map[string][]Modifier{"hash": {{Name: "SHA1", Arguments: []string{"PREFIX", "2"}}}},
+			expected:   4,
+		},
+		{attributes: []string{"lines"}, expected: 13},
+		{attributes: []string{"mime"}, expected: 13},
+		{
+			attributes: []string{"content"},
+			modifiers:  map[string][]Modifier{"content": {{Name: "MATCHES", Arguments: []string{"o"}}}},
+			expected:   13,
+		},
+	}
+
+	for _, c := range cases {
+		q := NewQuery()
+		q.Attributes = c.attributes
+		if c.modifiers != nil {
+			q.Modifiers = c.modifiers
+		}
+		if c.condition != nil {
+			q.ConditionTree = &ConditionNode{Condition: c.condition}
+		}
+		q.Sources["include"] = []string{"t"}
+		q.Options = &Options{Jobs: 1, FS: fsys, Stats: func(*Stats) {}}
+		if err := q.Execute(func(string, os.FileInfo, map[string]interface{}) {}); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+		if actual := q.Stats().BytesRead; actual != c.expected {
+			t.Fatalf("%v\nExpected %v\n     Got %v", c.attributes, c.expected, actual)
+		}
+	}
+}
+
+func TestStats_Selectivity(t *testing.T) {
+	type Case struct {
+		input    ConditionStats
+		expected float64
+	}
+
+	cases := []Case{
+		{input: ConditionStats{Evaluated: 4, Matched: 1}, expected: 0.25},
+		{input: ConditionStats{Evaluated: 4, Matched: 4}, expected: 1},
+		{input: ConditionStats{}, expected: 0},
+	}
+
+	for _, c := range cases {
+		if actual := c.input.Selectivity(); actual != c.expected {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected, actual)
+		}
+	}
+}
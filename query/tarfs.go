@@ -0,0 +1,363 @@
+package query
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// maxCachedMember is the size of the largest member whose contents are
+	// cached by a tarFS, and maxCachedTotal is the most it caches in total.
+	maxCachedMember = 1 << 20
+	maxCachedTotal  = 64 << 20
+)
+
+// tarFS is a read-only fs.FS over a (optionally gzipped) tar archive.
+//
+// Tar archives can't be read at random, so the archive's headers are indexed
+// once, when it's opened. The contents of members are read by a single
+// sequential pass over the archive, which advances as far as needed whenever a
+// member is opened, and caches the small members it passes (see
+// maxCachedMember), so that reading every member only reads the archive once.
+// Other members are streamed from the start of the archive whenever they're
+// opened.
+type tarFS struct {
+	fsys    fs.FS
+	name    string
+	gzipped bool
+	entries map[string]*tarEntry
+
+	// mu guards the pass over the archive. cursor reads the archive, if the
+	// pass has started, and next is the index of the next header it reads.
+	// cache holds the contents of the members the pass cached, keyed by index,
+	// and cached is their total size.
+	mu     sync.Mutex
+	cursor *tarReader
+	next   int
+	cache  map[int][]byte
+	cached int64
+}
+
+// tarEntry is a single file in a tarFS.
+type tarEntry struct {
+	name   string
+	header *tar.Header
+	// index is the position of the entry's header in the archive, or -1 for
+	// directories that are only implied by the names of other members.
+	index    int
+	children []*tarEntry
+}
+
+// newTarFS indexes the tar archive name in fsys.
+func newTarFS(fsys fs.FS, name string, gzipped bool) (*tarFS, error) {
+	t := &tarFS{
+		fsys:    fsys,
+		name:    name,
+		gzipped: gzipped,
+		entries: make(map[string]*tarEntry),
+		cache:   make(map[int][]byte),
+	}
+	t.entries["."] = &tarEntry{name: ".", header: dirHeader("."), index: -1}
+
+	r, err := t.open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	for i := 0; ; i++ {
+		header, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		if name, ok := memberName(header); ok {
+			t.add(name, header, i)
+		}
+	}
+
+	for _, e := range t.entries {
+		sort.Slice(e.children, func(i, j int) bool {
+			return e.children[i].name < e.children[j].name
+		})
+	}
+	return t, nil
+}
+
+// memberName returns the name of the member with the given header in a tarFS.
+// Like archive/zip, members with names that can't be represented in an fs.FS
+// (e.g. absolute paths or paths containing `..`) are skipped.
+func memberName(header *tar.Header) (string, bool) {
+	name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+	if name == "." || !fs.ValidPath(name) {
+		return "", false
+	}
+	return name, true
+}
+
+// dirHeader returns the header of a directory which isn't in the archive.
+func dirHeader(name string) *tar.Header {
+	return &tar.Header{Name: name + "/", Typeflag: tar.TypeDir, Mode: 0755}
+}
+
+// add adds the member name to the index, along with any parent directories
+// that don't have their own header. If a member appears more than once, the
+// last occurrence wins (as when extracting).
+func (t *tarFS) add(name string, header *tar.Header, index int) {
+	if e, ok := t.entries[name]; ok {
+		e.header, e.index = header, index
+		return
+	}
+
+	e := &tarEntry{name: name, header: header, index: index}
+	t.entries[name] = e
+
+	dir := path.Dir(name)
+	if _, ok := t.entries[dir]; !ok {
+		t.add(dir, dirHeader(dir), -1)
+	}
+	parent := t.entries[dir]
+	parent.children = append(parent.children, e)
+}
+
+// tarReader reads a tar archive from the start, closing the underlying file
+// (and decompressor) when it's closed.
+type tarReader struct {
+	*tar.Reader
+	closers []io.Closer
+}
+
+func (r *tarReader) Close() error {
+	var err error
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		if closeErr := r.closers[i].Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// open opens the archive for reading, from the start.
+func (t *tarFS) open() (*tarReader, error) {
+	f, err := t.fsys.Open(t.name)
+	if err != nil {
+		return nil, err
+	}
+	if !t.gzipped {
+		return &tarReader{Reader: tar.NewReader(f), closers: []io.Closer{f}}, nil
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &tarReader{Reader: tar.NewReader(gz), closers: []io.Closer{f, gz}}, nil
+}
+
+// lookup returns the entry for name.
+func (t *tarFS) lookup(op, name string) (*tarEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := t.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return e, nil
+}
+
+// Open implements fs.FS.
+func (t *tarFS) Open(name string) (fs.File, error) {
+	e, err := t.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if e.header.FileInfo().IsDir() {
+		return &tarDir{entry: e}, nil
+	}
+
+	if data, ok := t.contents(e); ok {
+		return &tarFile{Reader: bytes.NewReader(data), entry: e}, nil
+	}
+
+	r, err := t.open()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i <= e.index; i++ {
+		if _, err := r.Next(); err != nil {
+			r.Close()
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+	return &tarFile{Reader: r, closer: r, entry: e}, nil
+}
+
+// contents returns the cached contents of the member e, advancing the pass
+// over the archive up to e if it hasn't reached it yet. Returns false if e's
+// contents aren't cached, in which case they're streamed (which also reports
+// any error reading the archive).
+func (t *tarFS) contents(e *tarEntry) ([]byte, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if data, ok := t.cache[e.index]; ok {
+		return data, true
+	}
+	if e.index < t.next {
+		return nil, false
+	}
+
+	if t.cursor == nil {
+		r, err := t.open()
+		if err != nil {
+			return nil, false
+		}
+		t.cursor, t.next = r, 0
+	}
+	for t.next <= e.index {
+		header, err := t.cursor.Next()
+		if err == nil && t.cacheable(header, t.next) {
+			var data []byte
+			if data, err = io.ReadAll(t.cursor); err == nil {
+				t.cache[t.next] = data
+				t.cached += int64(len(data))
+			}
+		}
+		if err != nil {
+			// The rest of the archive is streamed, so that the error is
+			// reported by the members that can't be read.
+			t.cursor.Close()
+			t.cursor, t.next = nil, math.MaxInt
+			return nil, false
+		}
+		t.next++
+	}
+
+	data, ok := t.cache[e.index]
+	return data, ok
+}
+
+// cacheable reports whether the contents of the member at index, which has
+// the given header, are cached as the pass over the archive reaches it.
+func (t *tarFS) cacheable(header *tar.Header, index int) bool {
+	if !header.FileInfo().Mode().IsRegular() || header.Size > maxCachedMember ||
+		t.cached+header.Size > maxCachedTotal {
+		return false
+	}
+	// A member that's replaced by a later one with the same name can't be
+	// opened.
+	name, ok := memberName(header)
+	if !ok {
+		return false
+	}
+	e, ok := t.entries[name]
+	return ok && e.index == index
+}
+
+// Close ends the pass over the archive, if it's started, and drops the cached
+// contents of its members.
+func (t *tarFS) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.cache, t.cached = make(map[int][]byte), 0
+	if t.cursor == nil {
+		return nil
+	}
+	err := t.cursor.Close()
+	t.cursor, t.next = nil, 0
+	return err
+}
+
+// Stat implements fs.StatFS, without reading the archive.
+func (t *tarFS) Stat(name string) (fs.FileInfo, error) {
+	e, err := t.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return e.header.FileInfo(), nil
+}
+
+// ReadDir implements fs.ReadDirFS, without reading the archive.
+func (t *tarFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	e, err := t.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !e.header.FileInfo().IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return e.dirEntries(), nil
+}
+
+// dirEntries returns the entry's children, sorted by name.
+func (e *tarEntry) dirEntries() []fs.DirEntry {
+	entries := make([]fs.DirEntry, len(e.children))
+	for i, child := range e.children {
+		entries[i] = fs.FileInfoToDirEntry(child.header.FileInfo())
+	}
+	return entries
+}
+
+// tarFile is an open member of a tarFS, which is read either from its cached
+// contents or from its own reader of the archive (which is closed by closer).
+type tarFile struct {
+	io.Reader
+	closer io.Closer
+	entry  *tarEntry
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.entry.header.FileInfo(), nil }
+
+func (f *tarFile) Close() error {
+	if f.closer == nil {
+		return nil
+	}
+	return f.closer.Close()
+}
+
+// tarDir is an open directory of a tarFS.
+type tarDir struct {
+	entry  *tarEntry
+	offset int
+}
+
+func (d *tarDir) Stat() (fs.FileInfo, error) { return d.entry.header.FileInfo(), nil }
+
+func (d *tarDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.entry.name, Err: errors.New("is a directory")}
+}
+
+func (d *tarDir) Close() error { return nil }
+
+func (d *tarDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries := d.entry.dirEntries()[d.offset:]
+	if n > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(entries) {
+		entries = entries[:n]
+	}
+	d.offset += len(entries)
+	return entries, nil
+}
@@ -0,0 +1,470 @@
+package query
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kashav/fsql/transform"
+)
+
+// ErrLoop is reported for each directory which is one of its own parents,
+// which can only be reached by following symbolic links.
+var ErrLoop = errors.New("filesystem loop detected")
+
+// source is a file tree to walk, rooted at a single FROM source (or glob
+// match).
+type source struct {
+	fsys fs.FS
+
+	// root is the slash-separated path in fsys at which the walk starts, and
+	// path is the path that's shown for root.
+	root, path string
+
+	// container is the path of the archive containing the tree, if any.
+	container string
+}
+
+// locate returns the filesystem containing the file located at path (as
+// written in the FROM clause) and the file's name in that filesystem. Paths
+// are read from Options.FS if it's set, and from the OS filesystem otherwise.
+func (q *Query) locate(path string) (fs.FS, string) {
+	if fsys := q.options().FS; fsys != nil {
+		return fsys, filepath.ToSlash(path)
+	}
+
+	// Names in an fs.FS can't contain `..` or a leading slash, so the
+	// filesystem is rooted at the file's parent directory where possible.
+	if base := filepath.Base(path); base != "." && fs.ValidPath(base) {
+		return transform.DirFS(filepath.Dir(path)), base
+	}
+	return transform.DirFS(path), "."
+}
+
+// newSource returns the source for path, which is either a regular path or a
+// path into an archive.
+func (q *Query) newSource(path string, opts SourceOptions) (*source, error) {
+	a, name, err := q.findArchive(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	if a != nil {
+		return &source{
+			fsys:      a.fsys,
+			root:      name,
+			path:      filepath.Join(a.path, filepath.FromSlash(name)),
+			container: a.path,
+		}, nil
+	}
+
+	fsys, name := q.locate(path)
+	return &source{fsys: fsys, root: name, path: path}, nil
+}
+
+// walker walks sources, reading up to workers directories concurrently.
+type walker struct {
+	workers       int
+	unordered     bool
+	followLinks   bool
+	oneFilesystem bool
+	walkFn        filepath.WalkFunc
+	warn          func(error)
+
+	// descends reports whether walkFn would descend into the directory at
+	// path, without any side effects. walkOrdered only reads a directory ahead
+	// of time if descends is set and returns true for it.
+	descends func(path string, info os.FileInfo) bool
+
+	// dev is the device of the root of the source being walked, if the walk
+	// stays on one filesystem.
+	dev uint64
+
+	// loops holds the directories which were found to be in a loop, each of
+	// which is reported once.
+	loops map[fileID]bool
+
+	// sem limits the number of directories being read at once by walkOrdered.
+	sem chan struct{}
+}
+
+// newWalker returns a walker which calls walkFn for each file, as configured
+// by opts.
+func newWalker(opts *Options, walkFn filepath.WalkFunc) *walker {
+	workers := opts.Walkers
+	if workers < 1 {
+		workers = 1
+	}
+	return &walker{
+		workers:       workers,
+		unordered:     opts.Unordered && workers > 1,
+		followLinks:   opts.FollowLinks,
+		oneFilesystem: opts.OneFilesystem,
+		walkFn:        walkFn,
+		warn:          opts.warn,
+		loops:         make(map[fileID]bool),
+		sem:           make(chan struct{}, workers),
+	}
+}
+
+// walk walks the tree rooted at s, calling walkFn for each file (except the
+// root of an archive). Symbolic links are only followed if followLinks is set
+// or the filesystem doesn't support them. When following links, a directory
+// which is also one of its own parents isn't descended into, and is reported
+// (once) as a loop. If oneFilesystem is set, directories on a different device
+// than s's root (i.e. mount points) aren't descended into.
+//
+// Directories are passed to walkFn before they're read, as with fs.WalkDir:
+// if walkFn returns filepath.SkipDir, the directory isn't read, and if reading
+// fails, walkFn is called a second time with the error. Returning SkipDir for
+// a file skips the rest of its directory.
+//
+// Unless the walker is unordered, files are passed to walkFn in lexical order,
+// the same as a serial walk, even when directories are read concurrently.
+// Files are passed as a transform.FSFileInfo, so that their contents are read
+// from the source's filesystem.
+func (w *walker) walk(s *source) error {
+	info, err := transform.Lstat(s.fsys, s.root)
+	if err == nil && w.followLinks && info.Mode()&fs.ModeSymlink != 0 {
+		if target, err := fs.Stat(s.fsys, s.root); err == nil {
+			info = target
+		}
+	}
+
+	if err == nil && w.oneFilesystem {
+		w.dev, _, _ = transform.FileID(info)
+	}
+
+	if err != nil {
+		err = w.walkFn(s.path, nil, s.pathError(err, s.path))
+	} else if w.unordered {
+		err = w.walkUnordered(s, info)
+	} else {
+		err = w.walkOrdered(s, s.root, s.path, info, nil, nil)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// listing is the result of reading a directory, which may not have finished
+// yet.
+type listing struct {
+	done    chan struct{}
+	entries []fs.DirEntry
+	err     error
+}
+
+// read reads the directory name in s in a new goroutine.
+func (w *walker) read(s *source, name string) *listing {
+	l := &listing{done: make(chan struct{})}
+	go func() {
+		w.sem <- struct{}{}
+		l.entries, l.err = fs.ReadDir(s.fsys, name)
+		<-w.sem
+		close(l.done)
+	}()
+	return l
+}
+
+// visit passes the file name (shown as path) to walkFn, unless it's the root
+// of an archive.
+func (w *walker) visit(s *source, name, path string, info fs.FileInfo, err error) error {
+	if err == nil && s.container != "" && name == "." {
+		return nil
+	}
+	return w.walkFn(path, s.fileInfo(name, info), s.pathError(err, path))
+}
+
+// walkOrdered recursively walks the file name, shown as path. If it's a
+// directory, l holds its (pending) listing, or nil if it hasn't been read, and
+// parents holds its parent directories (if links are followed).
+//
+// When reading directories concurrently, the subdirectories of each directory
+// are read ahead of time, while the walk is busy with their earlier siblings.
+// Only those which walkFn (as per descends) and enter would descend into are
+// read ahead, so that excluded or pruned directories are never read.
+func (w *walker) walkOrdered(s *source, name, path string, info fs.FileInfo,
+	l *listing, parents *ancestor) error {
+	if err := w.visit(s, name, path, info, nil); err != nil || !info.IsDir() {
+		return err
+	}
+	parents, ok := w.enter(path, info, parents)
+	if !ok {
+		return nil
+	}
+
+	if l == nil {
+		l = w.read(s, name)
+	}
+	<-l.done
+	if l.err != nil {
+		if err := w.visit(s, name, path, info, l.err); err != nil {
+			return err
+		}
+	}
+
+	infos := make([]fs.FileInfo, len(l.entries))
+	for i, entry := range l.entries {
+		infos[i] = w.stat(s, pathpkg.Join(name, entry.Name()), entry)
+	}
+
+	ahead := make([]*listing, len(l.entries))
+	if w.workers > 1 && w.descends != nil {
+		for i, entry := range l.entries {
+			if !infos[i].IsDir() || !w.mayEnter(infos[i], parents) {
+				continue
+			}
+			child := pathpkg.Join(name, entry.Name())
+			if w.descends(filepath.Join(path, entry.Name()), s.fileInfo(child, infos[i])) {
+				ahead[i] = w.read(s, child)
+			}
+		}
+	}
+
+	for i, entry := range l.entries {
+		err := w.walkOrdered(s, pathpkg.Join(name, entry.Name()),
+			filepath.Join(path, entry.Name()), infos[i], ahead[i], parents)
+		if err == filepath.SkipDir && !infos[i].IsDir() {
+			return nil
+		}
+		if err != nil && err != filepath.SkipDir {
+			return err
+		}
+	}
+	return nil
+}
+
+// pendingDir is a directory that's waiting to be read by walkUnordered.
+type pendingDir struct {
+	name, path string
+	info       fs.FileInfo
+	parents    *ancestor
+
+	entries []fs.DirEntry
+	err     error
+}
+
+// walkUnordered walks the tree rooted at s, passing each file to walkFn as
+// soon as its directory has been read.
+func (w *walker) walkUnordered(s *source, info fs.FileInfo) error {
+	if err := w.visit(s, s.root, s.path, info, nil); err != nil || !info.IsDir() {
+		return err
+	}
+	parents, _ := w.enter(s.path, info, nil)
+
+	results := make(chan *pendingDir)
+	queue := []*pendingDir{{name: s.root, path: s.path, info: info, parents: parents}}
+	inFlight := 0
+
+	// If the walk ends early, the remaining reads are left to finish in the
+	// background.
+	defer func() {
+		go func(n int) {
+			for ; n > 0; n-- {
+				<-results
+			}
+		}(inFlight)
+	}()
+
+	for len(queue) > 0 || inFlight > 0 {
+		for inFlight < w.workers && len(queue) > 0 {
+			// Read the most recently found directory first, which keeps the
+			// queue short for deep trees.
+			d := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			inFlight++
+			go func() {
+				d.entries, d.err = fs.ReadDir(s.fsys, d.name)
+				results <- d
+			}()
+		}
+
+		d := <-results
+		inFlight--
+		if d.err != nil {
+			if err := w.visit(s, d.name, d.path, d.info, d.err); err == filepath.SkipDir {
+				continue
+			} else if err != nil {
+				return err
+			}
+		}
+
+		for _, entry := range d.entries {
+			name := pathpkg.Join(d.name, entry.Name())
+			path := filepath.Join(d.path, entry.Name())
+			info := w.stat(s, name, entry)
+
+			err := w.visit(s, name, path, info, nil)
+			if err == filepath.SkipDir {
+				if info.IsDir() {
+					continue
+				}
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				continue
+			}
+			if parents, ok := w.enter(path, info, d.parents); ok {
+				queue = append(queue, &pendingDir{name: name, path: path, info: info,
+					parents: parents})
+			}
+		}
+	}
+	return nil
+}
+
+// stat returns the FileInfo of the directory entry name in s. If links
+// are followed, the FileInfo of a symbolic link describes its destination
+// (unless the link is broken).
+func (w *walker) stat(s *source, name string, entry fs.DirEntry) fs.FileInfo {
+	if w.followLinks && entry.Type()&fs.ModeSymlink != 0 {
+		if info, err := fs.Stat(s.fsys, name); err == nil {
+			return info
+		}
+	}
+	return newEntryInfo(entry)
+}
+
+// fileID identifies a file by its device and inode numbers.
+type fileID struct {
+	dev, ino uint64
+}
+
+// ancestor is a directory on the path from a source's root to the directory
+// being walked.
+type ancestor struct {
+	id     fileID
+	parent *ancestor
+}
+
+// enter returns the ancestors of the contents of the directory located at
+// path, given the directory's own ancestors. Returns false if the directory
+// shouldn't be descended into, since it's one of its own parents or is on
+// another filesystem.
+//
+// Loops can only be found by following links, so ancestors are only tracked
+// while following links (and on platforms that identify files).
+func (w *walker) enter(path string, info fs.FileInfo, parents *ancestor) (*ancestor, bool) {
+	if !w.followLinks && !w.oneFilesystem {
+		return nil, true
+	}
+	dev, ino, ok := transform.FileID(info)
+	if !ok {
+		return parents, true
+	}
+	if w.oneFilesystem && dev != w.dev {
+		return nil, false
+	}
+	if !w.followLinks {
+		return nil, true
+	}
+
+	id := fileID{dev, ino}
+	if parents.contains(id) {
+		if !w.loops[id] {
+			w.loops[id] = true
+			w.warn(&fs.PathError{Op: "walk", Path: path, Err: ErrLoop})
+		}
+		return nil, false
+	}
+	return &ancestor{id: id, parent: parents}, true
+}
+
+// mayEnter reports whether enter would allow descending into the directory
+// described by info, without reporting loops.
+func (w *walker) mayEnter(info fs.FileInfo, parents *ancestor) bool {
+	if !w.followLinks && !w.oneFilesystem {
+		return true
+	}
+	dev, ino, ok := transform.FileID(info)
+	if !ok {
+		return true
+	}
+	if w.oneFilesystem && dev != w.dev {
+		return false
+	}
+	return !w.followLinks || !parents.contains(fileID{dev, ino})
+}
+
+// contains reports whether the directory identified by id is a or one of its
+// parents.
+func (a *ancestor) contains(id fileID) bool {
+	for ; a != nil; a = a.parent {
+		if a.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// entryInfo is the FileInfo of a directory entry. Reading a directory only
+// provides the name and type of each entry, so the entry is only stat'd when
+// the rest of its FileInfo is needed. If the entry can't be stat'd (e.g. if it
+// has since been removed), its size and time are zero.
+type entryInfo struct {
+	entry fs.DirEntry
+	once  sync.Once
+	info  fs.FileInfo
+}
+
+func newEntryInfo(entry fs.DirEntry) *entryInfo {
+	return &entryInfo{entry: entry}
+}
+
+func (e *entryInfo) stat() fs.FileInfo {
+	e.once.Do(func() {
+		info, err := e.entry.Info()
+		if err != nil {
+			info = &zeroInfo{e.entry}
+		}
+		e.info = info
+	})
+	return e.info
+}
+
+func (e *entryInfo) Name() string       { return e.entry.Name() }
+func (e *entryInfo) IsDir() bool        { return e.entry.IsDir() }
+func (e *entryInfo) Size() int64        { return e.stat().Size() }
+func (e *entryInfo) Mode() fs.FileMode  { return e.stat().Mode() }
+func (e *entryInfo) ModTime() time.Time { return e.stat().ModTime() }
+func (e *entryInfo) Sys() interface{}   { return e.stat().Sys() }
+
+// zeroInfo is the FileInfo of a directory entry which couldn't be stat'd.
+type zeroInfo struct {
+	entry fs.DirEntry
+}
+
+func (z *zeroInfo) Name() string       { return z.entry.Name() }
+func (z *zeroInfo) IsDir() bool        { return z.entry.IsDir() }
+func (z *zeroInfo) Size() int64        { return 0 }
+func (z *zeroInfo) Mode() fs.FileMode  { return z.entry.Type() }
+func (z *zeroInfo) ModTime() time.Time { return time.Time{} }
+func (z *zeroInfo) Sys() interface{}   { return nil }
+
+// fileInfo returns the os.FileInfo passed to walkFn for the file name.
+func (s *source) fileInfo(name string, info fs.FileInfo) os.FileInfo {
+	return &transform.FSFileInfo{
+		FileInfo:  info,
+		FS:        s.fsys,
+		FSPath:    name,
+		Container: s.container,
+	}
+}
+
+// pathError replaces the path of err (which is a name in the source's
+// filesystem) with path, so that errors refer to files as they're shown.
+func (s *source) pathError(err error, path string) error {
+	var pathErr *fs.PathError
+	if err == nil || !errors.As(err, &pathErr) {
+		return err
+	}
+	return &fs.PathError{Op: pathErr.Op, Path: path, Err: pathErr.Err}
+}
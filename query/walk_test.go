@@ -0,0 +1,537 @@
+package query
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kashav/fsql/tokenizer"
+	"github.com/kashav/fsql/transform"
+)
+
+func TestWalk_ExecuteFS(t *testing.T) {
+	type Case struct {
+		sources  []string
+		exclude  []string
+		expected []string
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	fw, _ := w.Create("docs/a.txt")
+	fw.Write([]byte("foo"))
+	w.Close()
+
+	fsys := fstest.MapFS{
+		"a.txt":       {Data: []byte("foo")},
+		"empty":       {Mode: fs.ModeDir | 0755},
+		"sub/b.txt":   {Data: []byte("bar")},
+		"sub/c.go":    {Data: []byte("package c")},
+		"sub/deep/d":  {Data: []byte("baz")},
+		"release.zip": {Data: buf.Bytes()},
+		"sub/other":   {Mode: fs.ModeDir | 0755},
+	}
+
+	cases := []Case{
+		{
+			sources: []string{"."},
+			expected: []string{"a.txt", "empty", "release.zip", "sub", "sub/b.txt",
+				"sub/c.go", "sub/deep", "sub/deep/d", "sub/other"},
+		},
+		{
+			sources:  []string{"sub"},
+			exclude:  []string{"sub/deep"},
+			expected: []string{"sub", "sub/b.txt", "sub/c.go", "sub/other"},
+		},
+		{
+			sources:  []string{"sub/*.go", "a.txt"},
+			expected: []string{"sub/c.go", "a.txt"},
+		},
+		{
+			sources:  []string{"release.zip"},
+			expected: []string{"release.zip/docs", "release.zip/docs/a.txt"},
+		},
+	}
+
+	for _, c := range cases {
+		q := NewQuery()
+		q.Sources["include"] = c.sources
+		q.Sources["exclude"] = c.exclude
+		q.Options = &Options{Jobs: 1, FS: fsys}
+
+		actual := make([]string, 0)
+		if err := q.Execute(
+			func(path string, info os.FileInfo, _ map[string]interface{}) {
+				actual = append(actual, filepath.ToSlash(path))
+			},
+		); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+		if !reflect.DeepEqual(c.expected, actual) {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected, actual)
+		}
+	}
+}
+
+func TestWalk_ExecuteFSContent(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte("foo")},
+		"b.txt": {Data: []byte("bar")},
+	}
+
+	q := NewQuery()
+	q.Sources["include"] = []string{"."}
+	q.Options = &Options{Jobs: 1, FS: fsys}
+
+	expected := map[string]interface{}{
+		"a.txt": "0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33",
+		"b.txt": "62cdb7020ff920e5aa642c3d4066950dd1f01f4d",
+	}
+	actual := map[string]interface{}{}
+	if err := q.Execute(
+		func(path string, info os.FileInfo, _ map[string]interface{}) {
+			if info.IsDir() {
+				return
+			}
+			hash, err := transform.HashFile(info, path, "SHA1", -1)
+			if err != nil {
+				t.Fatalf("\nExpected no error\n     Got %v", err)
+			}
+			actual[path] = hash
+		},
+	); err != nil {
+		t.Fatalf("\nExpected no error\n     Got %v", err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("\nExpected %v\n     Got %v", expected, actual)
+	}
+}
+
+func TestWalk_ExecuteFSNull(t *testing.T) {
+	type Case struct {
+		condition *Condition
+		expected  []string
+	}
+
+	fsys := fstest.MapFS{
+		"one.txt": {Data: []byte("a\n")},
+		"two.txt": {Data: []byte("a\nb\nc\n")},
+		"bin":     {Data: []byte("a\x00b\n")},
+		"sub/c":   {Data: []byte("c\nd\n")},
+	}
+
+	cases := []Case{
+		{
+			condition: &Condition{Attribute: "lines", Operator: tokenizer.GreaterThan, Value: "1"},
+			expected:  []string{"sub/c", "two.txt"},
+		},
+		{
+			condition: &Condition{Attribute: "lines", Operator: tokenizer.GreaterThan, Value: "1", Negate: true},
+			expected:  []string{"one.txt"},
+		},
+		{
+			condition: &Condition{Attribute: "is_text", Operator: tokenizer.Equals, Value: "true"},
+			expected:  []string{"one.txt", "sub/c", "two.txt"},
+		},
+		{
+			condition: &Condition{Attribute: "is_text", Operator: tokenizer.Equals, Value: "true", Negate: true},
+			expected:  []string{"bin"},
+		},
+	}
+
+	for _, c := range cases {
+		q := NewQuery()
+		q.Sources["include"] = []string{"."}
+		q.ConditionTree = &ConditionNode{Condition: c.condition}
+		q.Options = &Options{Jobs: 1, FS: fsys}
+
+		actual := make([]string, 0)
+		if err := q.Execute(
+			func(path string, info os.FileInfo, _ map[string]interface{}) {
+				actual = append(actual, filepath.ToSlash(path))
+			},
+		); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+		sort.Strings(actual)
+		if !reflect.DeepEqual(c.expected, actual) {
+			t.Fatalf("%v\nExpected %v\n     Got %v", c.condition, c.expected, actual)
+		}
+	}
+}
+
+func TestWalk_ExecuteFSContentOptions(t *testing.T) {
+	type Case struct {
+		options  *Options
+		expected []string
+	}
+
+	fsys := fstest.MapFS{
+		"bin":   {Data: []byte("a\x00TODO")},
+		"long":  {Data: []byte(strings.Repeat(".", 64) + "TODO")},
+		"short": {Data: []byte("TODO")},
+	}
+
+	cases := []Case{
+		{options: &Options{}, expected: []string{"long", "short"}},
+		{options: &Options{Binary: true}, expected: []string{"bin", "long", "short"}},
+		{options: &Options{MaxContentSize: 32, Binary: true}, expected: []string{"bin", "short"}},
+	}
+
+	for _, c := range cases {
+		q := NewQuery()
+		q.Sources["include"] = []string{"."}
+		q.ConditionTree = &ConditionNode{Condition: &Condition{
+			Attribute: "content",
+			Operator:  tokenizer.Contains,
+			Value:     "TODO",
+		}}
+		c.options.Jobs, c.options.FS = 1, fsys
+		q.Options = c.options
+
+		actual := make([]string, 0)
+		if err := q.Execute(
+			func(path string, info os.FileInfo, _ map[string]interface{}) {
+				actual = append(actual, path)
+			},
+		); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+		if !reflect.DeepEqual(c.expected, actual) {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected, actual)
+		}
+	}
+}
+
+func TestWalk_ExecuteFSMissingSource(t *testing.T) {
+	q := NewQuery()
+	q.Sources["include"] = []string{"missing"}
+	q.Options = &Options{Jobs: 1, FS: fstest.MapFS{}}
+
+	err := q.Execute(func(string, os.FileInfo, map[string]interface{}) {})
+	if pathErr, ok := err.(*fs.PathError); !ok || pathErr.Path != "missing" {
+		t.Fatalf("\nExpected missing: file does not exist\n     Got %v", err)
+	}
+}
+
+func TestWalk_SkipDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/1":   {},
+		"a/2":   {},
+		"b/1":   {},
+		"b/2":   {},
+		"c/1/x": {},
+		"c/2":   {},
+	}
+
+	for _, opts := range []*Options{
+		{Walkers: 1},
+		{Walkers: 4},
+		{Walkers: 4, Unordered: true},
+	} {
+		actual := make([]string, 0)
+		w := newWalker(opts, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			actual = append(actual, path)
+			switch path {
+			case "a/1", "c/1":
+				// Skipping from a file skips the rest of its directory, while
+				// skipping from a directory skips its contents.
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if err := w.walk(&source{fsys: fsys, root: ".", path: "."}); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+
+		expected := []string{".", "a", "a/1", "b", "b/1", "b/2", "c", "c/1", "c/2"}
+		if opts.Unordered {
+			sort.Strings(actual)
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Fatalf("\nExpected %v\n     Got %v", expected, actual)
+		}
+	}
+}
+
+func TestWalk_Walkers(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 5; j++ {
+			fsys[fmt.Sprintf("%02d/%d/%d/f", i, j, i*j)] = &fstest.MapFile{}
+		}
+	}
+
+	paths := func(opts *Options) []string {
+		q := NewQuery()
+		q.Sources["include"] = []string{"."}
+		q.Options = opts
+		opts.FS = fsys
+
+		result := make([]string, 0)
+		if err := q.Execute(
+			func(path string, info os.FileInfo, _ map[string]interface{}) {
+				result = append(result, path)
+			},
+		); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+		return result
+	}
+
+	expected := paths(&Options{Jobs: 1, Walkers: 1})
+	if len(expected) != 20*(1+5*3) {
+		t.Fatalf("\nExpected %d results\n     Got %d", 20*(1+5*3), len(expected))
+	}
+	for _, walkers := range []int{2, 4, 16} {
+		if actual := paths(&Options{Jobs: 4, Walkers: walkers}); !reflect.DeepEqual(expected, actual) {
+			t.Fatalf("\nExpected %v\n     Got %v", expected, actual)
+		}
+
+		actual := paths(&Options{Jobs: 4, Walkers: walkers, Unordered: true})
+		sort.Strings(actual)
+		if !reflect.DeepEqual(expected, actual) {
+			t.Fatalf("\nExpected %v\n     Got %v", expected, actual)
+		}
+	}
+}
+
+// readCountingFS is a filesystem which records each directory that's read.
+type readCountingFS struct {
+	fstest.MapFS
+	mu   sync.Mutex
+	read []string
+}
+
+func (f *readCountingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f.mu.Lock()
+	f.read = append(f.read, name)
+	f.mu.Unlock()
+	return f.MapFS.ReadDir(name)
+}
+
+func TestWalk_ReadAhead(t *testing.T) {
+	type Case struct {
+		exclude  []string
+		opts     *SourceOptions
+		tree     *ConditionNode
+		expected []string
+	}
+
+	cases := []Case{
+		{
+			expected: []string{".", "deep", "deep/d", "node_modules", "node_modules/x", "src", "src/lib"},
+		},
+		{
+			exclude:  []string{"node_modules"},
+			expected: []string{".", "deep", "deep/d", "src", "src/lib"},
+		},
+		{
+			opts:     &SourceOptions{MaxDepth: 1},
+			expected: []string{"."},
+		},
+		{
+			tree: &ConditionNode{Condition: &Condition{
+				Attribute: "path",
+				Operator:  tokenizer.Like,
+				Value:     "deep%",
+				Negate:    true,
+			}},
+			expected: []string{".", "node_modules", "node_modules/x", "src", "src/lib"},
+		},
+	}
+
+	for _, c := range cases {
+		fsys := &readCountingFS{MapFS: fstest.MapFS{
+			"src/a.go":            {},
+			"src/lib/b.go":        {},
+			"node_modules/x/y.js": {},
+			"deep/d/e":            {},
+		}}
+
+		q := NewQuery()
+		q.Sources["include"] = []string{"."}
+		q.Sources["exclude"] = c.exclude
+		if c.opts != nil {
+			q.SourceOptions["."] = c.opts
+		}
+		q.ConditionTree = c.tree
+		q.Options = &Options{Jobs: 1, Walkers: 4, FS: fsys}
+		if err := q.Execute(func(string, os.FileInfo, map[string]interface{}) {}); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+
+		sort.Strings(fsys.read)
+		if !reflect.DeepEqual(c.expected, fsys.read) {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected, fsys.read)
+		}
+	}
+}
+
+func TestWalk_Depth(t *testing.T) {
+	type Case struct {
+		sources  []string
+		options  map[string]*SourceOptions
+		min, max int
+		expected []string
+	}
+
+	fsys := fstest.MapFS{
+		"a/b/c/d": {},
+		"a/e":     {},
+		"f":       {},
+	}
+
+	cases := []Case{
+		{sources: []string{"."}, max: 1, expected: []string{"a", "f"}},
+		{sources: []string{"."}, max: 2, expected: []string{"a", "a/b", "a/e", "f"}},
+		{sources: []string{"."}, min: 3, expected: []string{"a/b/c", "a/b/c/d"}},
+		{sources: []string{"a"}, min: 1, max: 1, expected: []string{"a/b", "a/e"}},
+		{
+			sources:  []string{"a/b", "."},
+			options:  map[string]*SourceOptions{"a/b": {MaxDepth: 1}},
+			max:      1,
+			expected: []string{"a/b", "a/b/c", "a", "f"},
+		},
+		{
+			sources:  []string{"a"},
+			options:  map[string]*SourceOptions{"a": {MinDepth: 2}},
+			min:      1,
+			expected: []string{"a/b/c", "a/b/c/d"},
+		},
+	}
+
+	for _, c := range cases {
+		q := NewQuery()
+		q.Sources["include"] = c.sources
+		if c.options != nil {
+			q.SourceOptions = c.options
+		}
+		q.Options = &Options{Jobs: 1, MinDepth: c.min, MaxDepth: c.max, FS: fsys}
+
+		actual := make([]string, 0)
+		if err := q.Execute(
+			func(path string, info os.FileInfo, _ map[string]interface{}) {
+				actual = append(actual, filepath.ToSlash(path))
+			},
+		); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+		if !reflect.DeepEqual(c.expected, actual) {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected, actual)
+		}
+	}
+}
+
+func TestWalk_FollowLinks(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "real", "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "real", "sub", "a"), nil, 0644)
+	if err := os.Symlink("..", filepath.Join(dir, "real", "sub", "up")); err != nil {
+		t.Skipf("symbolic links aren't supported: %v", err)
+	}
+	os.Mkdir(filepath.Join(dir, "src"), 0755)
+	os.Symlink(filepath.Join("..", "real"), filepath.Join(dir, "src", "linked"))
+
+	paths := func(src string, opts *Options, sourceOpts *SourceOptions) ([]string, []error) {
+		warnings := make([]error, 0)
+		opts.Warn = func(err error) { warnings = append(warnings, err) }
+
+		q := NewQuery()
+		q.Sources["include"] = []string{src}
+		if sourceOpts != nil {
+			q.SourceOptions[src] = sourceOpts
+		}
+		q.Options = opts
+
+		result := make([]string, 0)
+		if err := q.Execute(
+			func(path string, info os.FileInfo, _ map[string]interface{}) {
+				rel, _ := filepath.Rel(dir, path)
+				result = append(result, filepath.ToSlash(rel))
+			},
+		); err != nil {
+			t.Fatalf("\nExpected no error\n     Got %v", err)
+		}
+		return result, warnings
+	}
+
+	src := filepath.Join(dir, "src")
+	expected := []string{"src", "src/linked"}
+	if actual, _ := paths(src, &Options{Jobs: 1}, nil); !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("\nExpected %v\n     Got %v", expected, actual)
+	}
+
+	// src/linked/sub/up leads back to src/linked, which is reported once and
+	// not descended into.
+	expected = []string{"src", "src/linked", "src/linked/sub", "src/linked/sub/a",
+		"src/linked/sub/up"}
+	for _, opts := range []*Options{
+		{Jobs: 1, FollowLinks: true},
+		{Jobs: 1, Walkers: 4, FollowLinks: true},
+		{Jobs: 1, Walkers: 4, Unordered: true, FollowLinks: true},
+	} {
+		actual, warnings := paths(src, opts, nil)
+		if opts.Unordered {
+			sort.Strings(actual)
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Fatalf("\nExpected %v\n     Got %v", expected, actual)
+		}
+		if len(warnings) != 1 || !errors.Is(warnings[0], ErrLoop) {
+			t.Fatalf("\nExpected 1 loop\n     Got %v", warnings)
+		}
+	}
+
+	actual, _ := paths(src, &Options{Jobs: 1}, &SourceOptions{FollowLinks: true})
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("\nExpected %v\n     Got %v", expected, actual)
+	}
+
+	// Following a link to the source itself.
+	actual, _ = paths(filepath.Join(src, "linked"), &Options{Jobs: 1, FollowLinks: true}, nil)
+	if !reflect.DeepEqual(expected[1:], actual) {
+		t.Fatalf("\nExpected %v\n     Got %v", expected[1:], actual)
+	}
+}
+
+func TestWalk_OneFilesystem(t *testing.T) {
+	root, err := os.Stat("/")
+	if err != nil {
+		t.Skipf("/ isn't available: %v", err)
+	}
+	proc, err := os.Stat("/proc")
+	if err != nil || transform.Device(proc) == nil ||
+		transform.Device(proc) == transform.Device(root) {
+		t.Skip("/proc isn't a separate filesystem")
+	}
+
+	q := NewQuery()
+	q.Sources["include"] = []string{"/"}
+	q.SourceOptions["/"] = &SourceOptions{MaxDepth: 2, OneFilesystem: true}
+	q.Options = &Options{Jobs: 1, Walkers: 1, Warn: func(error) {}}
+
+	visited := map[string]bool{}
+	q.Execute(func(path string, info os.FileInfo, _ map[string]interface{}) {
+		visited[path] = true
+	})
+	if !visited["/proc"] {
+		t.Fatalf("\nExpected /proc to be visited\n     Got %v", visited)
+	}
+	if visited["/proc/self"] {
+		t.Fatalf("\nExpected /proc not to be descended into\n     Got %v", visited)
+	}
+}
@@ -2,24 +2,39 @@ package terminal
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 
 	"github.com/kashav/fsql"
+	"github.com/kashav/fsql/query"
 	"github.com/kashav/fsql/terminal/pager"
 
 	"golang.org/x/crypto/ssh/terminal"
 )
 
 var fd = int(os.Stdin.Fd())
-var query bytes.Buffer
+var input bytes.Buffer
+
+// options holds the options each query is executed with.
+var options *query.Options
+
+// timing is set by `\timing`, in which case the statistics of each query are
+// printed after its results.
+var timing bool
+
+// Start listens for queries via stdin and invokes fsql.RunContext whenever a
+// semicolon is read. Ctrl-C cancels the current query, statements of the
+// form `SET <option> <value>;` change the options of subsequent queries, and
+// `\timing` toggles printing the statistics of each query.
+func Start(opts *query.Options) error {
+	options = opts
 
-// Start listens for queries via stdin and invokes fsql.Run whenever a
-// semicolon is read.
-func Start() error {
 	if !terminal.IsTerminal(fd) {
 		return errors.New("not a terminal")
 	}
@@ -33,6 +48,16 @@ func Start() error {
 	prompt := ">>> "
 	term := terminal.NewTerminal(os.Stdin, prompt)
 
+	// Warnings are written through the terminal, which translates newlines
+	// while in raw mode.
+	if options != nil && options.Warn == nil {
+		withWarn := *options
+		withWarn.Warn = func(err error) {
+			fmt.Fprintf(term, "fsql: %v\n", err)
+		}
+		options = &withWarn
+	}
+
 	// Listen for queries and invoke run whenever a semicolon is read. Continues
 	// until receiving an EOF (Ctrl-D) or _fatal_ error (i.e. anything not
 	// caused by the query itself).
@@ -51,25 +76,51 @@ func Start() error {
 			break
 		}
 
+		if input.Len() == 0 {
+			if ok, err := toggleTiming(line); ok {
+				msg := []byte("Timing is off.\n")
+				if err != nil {
+					msg = append([]byte(err.Error()), '\a', '\n')
+				} else if timing {
+					msg = []byte("Timing is on.\n")
+				}
+				term.Write(msg)
+				continue
+			}
+		}
+
 		// TODO: If the previous character was a paren., bracket, or quote, we
 		// don't want to add a space here (although not necessary, since the
 		// tokenizer handles excess whitespace).
-		if query.Len() > 0 {
-			query.WriteString(" ")
+		if input.Len() > 0 {
+			input.WriteString(" ")
 		}
-		query.WriteString(line)
+		input.WriteString(line)
 
 		if strings.HasSuffix(line, ";") {
-			query.Truncate(query.Len() - 1)
+			input.Truncate(input.Len() - 1)
 
+			if ok, err := set(input.String()); ok {
+				if err != nil {
+					term.Write(append([]byte(err.Error()), '\a', '\n'))
+				}
+				input.Reset()
+				term.SetPrompt(">>> ")
+				continue
+			}
+
+			// Queries run in the terminal's original mode, so that Ctrl-C sends
+			// an interrupt (which cancels the query) rather than being read as
+			// input.
+			if err := terminal.Restore(fd, state); err != nil {
+				return err
+			}
 			b := []byte{}
-			if out, err := run(query.String()); err != nil {
-				// This error likely corresponds to the query, so instead of exiting
-				// interactive mode, we simply write the error to stdout and proceed.
-				b = append(b, []byte(err.Error())...)
-				b = append(b, '\a', '\n')
-				term.Write(b)
-			} else if len(out) > 0 {
+			out, runErr := run(input.String())
+			if _, err := terminal.MakeRaw(fd); err != nil {
+				return err
+			}
+			if len(out) > 0 {
 				_, h, err := terminal.GetSize(fd)
 				if err != nil {
 					return err
@@ -87,12 +138,20 @@ func Start() error {
 					return err
 				}
 			}
+			if runErr != nil {
+				// This error likely corresponds to the query (or, if there's also
+				// output, to files which were skipped or the query being
+				// interrupted), so instead of exiting interactive mode, we simply
+				// write the error to stdout and proceed.
+				b = append([]byte(runErr.Error()), '\a', '\n')
+				term.Write(b)
+			}
 
-			query.Reset()
+			input.Reset()
 		}
 
 		prompt = "... "
-		if query.Len() == 0 {
+		if input.Len() == 0 {
 			prompt = ">>> "
 		}
 		term.SetPrompt(prompt)
@@ -100,8 +159,66 @@ func Start() error {
 	return nil
 }
 
-// run invokes fsql.Run with the provided query string.
-func run(query string) (out string, err error) {
+// set handles a `SET <option> <value>` statement, which changes the options
+// of each subsequent query. Returns false if stmt isn't a SET statement.
+//
+// The only option is `timeout`, a duration such as `10s` (or 0 for no limit).
+func set(stmt string) (bool, error) {
+	fields := strings.Fields(stmt)
+	if len(fields) == 0 || !strings.EqualFold(fields[0], "SET") {
+		return false, nil
+	}
+	if len(fields) != 3 {
+		return true, errors.New("expected SET <option> <value>")
+	}
+
+	opts := query.DefaultOptions()
+	if options != nil {
+		*opts = *options
+	}
+	switch strings.ToLower(fields[1]) {
+	case "timeout":
+		timeout, err := time.ParseDuration(fields[2])
+		if err != nil {
+			return true, err
+		}
+		if timeout < 0 {
+			return true, fmt.Errorf("invalid timeout %s", fields[2])
+		}
+		opts.Timeout = timeout
+	default:
+		return true, fmt.Errorf("unknown option %s", fields[1])
+	}
+	options = opts
+	return true, nil
+}
+
+// toggleTiming handles a `\timing [on|off]` command, which toggles (or sets)
+// whether the statistics of each query are printed. Returns false if line
+// isn't a \timing command.
+func toggleTiming(line string) (bool, error) {
+	fields := strings.Fields(strings.TrimSuffix(line, ";"))
+	if len(fields) == 0 || fields[0] != `\timing` {
+		return false, nil
+	}
+	switch {
+	case len(fields) == 1:
+		timing = !timing
+	case len(fields) == 2 && strings.EqualFold(fields[1], "on"):
+		timing = true
+	case len(fields) == 2 && strings.EqualFold(fields[1], "off"):
+		timing = false
+	default:
+		return true, errors.New(`expected \timing [on|off]`)
+	}
+	return true, nil
+}
+
+// run invokes fsql.RunContext with the query stmt. The query is cancelled on
+// an interrupt (i.e. Ctrl-C), in which case its partial results are returned
+// along with the error. If timing is set, the query's statistics follow its
+// results.
+func run(stmt string) (out string, err error) {
 	stdout := os.Stdout
 	r, w, err := os.Pipe()
 	if err != nil {
@@ -117,11 +234,38 @@ func run(query string) (out string, err error) {
 		ch <- buf.String()
 	}()
 
-	err = fsql.Run(query)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	opts := options
+	var stats *query.Stats
+	if timing {
+		withStats := query.DefaultOptions()
+		if options != nil {
+			*withStats = *options
+		}
+		withStats.Stats = func(s *query.Stats) { stats = s }
+		opts = withStats
+	}
+
+	err = fsql.RunContext(ctx, stmt, opts)
 	// Must happen after the function call and before we try to read from ch.
 	if closeErr := w.Close(); closeErr != nil {
 		return "", closeErr
 	}
 	out = <-ch
+	if stats != nil {
+		out += stats.String()
+	}
 	return
 }
@@ -3,7 +3,11 @@ package terminal
 import (
 	"errors"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/kashav/fsql/query"
 )
 
 func TestRun(t *testing.T) {
@@ -50,3 +54,92 @@ func TestRun(t *testing.T) {
 		}
 	}
 }
+
+func TestSet(t *testing.T) {
+	type Expected struct {
+		ok      bool
+		timeout time.Duration
+		err     bool
+	}
+
+	type Case struct {
+		stmt     string
+		expected Expected
+	}
+
+	cases := []Case{
+		{stmt: "SET timeout 10s", expected: Expected{ok: true, timeout: 10 * time.Second}},
+		{stmt: "set TIMEOUT 0", expected: Expected{ok: true, timeout: 0}},
+		{stmt: "SET timeout -1s", expected: Expected{ok: true, err: true}},
+		{stmt: "SET timeout ten", expected: Expected{ok: true, err: true}},
+		{stmt: "SET jobs 4", expected: Expected{ok: true, err: true}},
+		{stmt: "SET timeout", expected: Expected{ok: true, err: true}},
+		{stmt: "SELECT name FROM .", expected: Expected{ok: false}},
+	}
+
+	defer func(opts *query.Options) { options = opts }(options)
+	for _, c := range cases {
+		options = nil
+		ok, err := set(c.stmt)
+		if ok != c.expected.ok || (err != nil) != c.expected.err {
+			t.Fatalf("\nExpected %v (error: %v)\n     Got %v (%v)", c.expected.ok,
+				c.expected.err, ok, err)
+		}
+		if err == nil && ok && options.Timeout != c.expected.timeout {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected.timeout, options.Timeout)
+		}
+	}
+}
+
+func TestToggleTiming(t *testing.T) {
+	type Expected struct {
+		ok     bool
+		timing bool
+		err    bool
+	}
+
+	type Case struct {
+		line     string
+		expected Expected
+	}
+
+	cases := []Case{
+		{line: `\timing`, expected: Expected{ok: true, timing: true}},
+		{line: `\timing`, expected: Expected{ok: true, timing: false}},
+		{line: `\timing ON`, expected: Expected{ok: true, timing: true}},
+		{line: `\timing on;`, expected: Expected{ok: true, timing: true}},
+		{line: `\timing off`, expected: Expected{ok: true, timing: false}},
+		{line: `\timing maybe`, expected: Expected{ok: true, err: true}},
+		{line: "SELECT name FROM .", expected: Expected{ok: false}},
+	}
+
+	defer func(t bool) { timing = t }(timing)
+	timing = false
+	for _, c := range cases {
+		ok, err := toggleTiming(c.line)
+		if ok != c.expected.ok || (err != nil) != c.expected.err {
+			t.Fatalf("\nExpected %v (error: %v)\n     Got %v (%v)", c.expected.ok,
+				c.expected.err, ok, err)
+		}
+		if ok && err == nil && timing != c.expected.timing {
+			t.Fatalf("\nExpected %v\n     Got %v", c.expected.timing, timing)
+		}
+	}
+}
+
+func TestRunTiming(t *testing.T) {
+	defer func(t bool) { timing = t }(timing)
+	timing = true
+
+	out, err := run("select name from ../testdata where name = baz")
+	if err != nil {
+		t.Fatalf("\nExpected no error\n     Got %v", err)
+	}
+	expected := "baz\nfiles:"
+	if !strings.HasPrefix(out, expected) {
+		t.Fatalf("\nExpected %v...\n     Got %v", expected, out)
+	}
+	if !strings.Contains(out, "name = baz") {
+		t.Fatalf("\nExpected statistics of name = baz\n     Got %v", out)
+	}
+}
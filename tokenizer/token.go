@@ -24,7 +24,10 @@ const (
 	In
 	Is
 	Like
+	ILike
 	RLike
+	Glob
+	Contains
 
 	Equals
 	NotEquals
@@ -68,8 +71,14 @@ func (t TokenType) String() string {
 		return "is"
 	case Like:
 		return "like"
+	case ILike:
+		return "ilike"
 	case RLike:
 		return "RLike"
+	case Glob:
+		return "glob"
+	case Contains:
+		return "contains"
 	case Equals:
 		return "equal"
 	case NotEquals:
@@ -116,12 +125,32 @@ func (t *Token) String() string {
 type Tokenizer struct {
 	input  []rune
 	tokens []*Token
+
+	// length is the length of the full input, and start is the offset of the
+	// token being read. positions holds the offset of each token in tokens.
+	length    int
+	start     int
+	positions []int
 }
 
 // NewTokenizer initializes a new Tokenizer.
 func NewTokenizer(input string) *Tokenizer {
+	runes := []rune(input)
 	return &Tokenizer{
-		input:  []rune(input),
-		tokens: make([]*Token, 0),
+		input:     runes,
+		tokens:    make([]*Token, 0),
+		length:    len(runes),
+		positions: make([]int, 0),
+	}
+}
+
+// Position returns the offset (in runes) of tok in the input, or -1 if tok
+// wasn't read by this Tokenizer.
+func (t *Tokenizer) Position(tok *Token) int {
+	for i := len(t.tokens) - 1; i >= 0; i-- {
+		if t.tokens[i] == tok {
+			return t.positions[i]
+		}
 	}
+	return -1
 }
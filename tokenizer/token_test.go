@@ -21,7 +21,10 @@ func TestToken_TokenTypeString(t *testing.T) {
 		{tt: In, expected: "in"},
 		{tt: Is, expected: "is"},
 		{tt: Like, expected: "like"},
+		{tt: ILike, expected: "ilike"},
 		{tt: RLike, expected: "RLike"},
+		{tt: Glob, expected: "glob"},
+		{tt: Contains, expected: "contains"},
 		{tt: Equals, expected: "equal"},
 		{tt: NotEquals, expected: "not-equal"},
 		{tt: GreaterThanEquals, expected: "greater-than-or-equal"},
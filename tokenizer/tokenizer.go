@@ -25,6 +25,7 @@ func (t *Tokenizer) Next() *Token {
 	if current == -1 {
 		return nil
 	}
+	t.start = t.length - len(t.input)
 
 	switch current {
 	case '(':
@@ -50,6 +51,7 @@ func (t *Tokenizer) Next() *Token {
 			t.input = t.input[2:]
 			return t.setToken(&Token{Type: NotEquals, Raw: "!="})
 		}
+		t.input = t.input[1:]
 		return t.setToken(&Token{Type: ExclamationMark, Raw: "!"})
 	case '=':
 		t.input = t.input[1:]
@@ -99,8 +101,14 @@ func (t *Tokenizer) Next() *Token {
 			tok.Type = Is
 		case "LIKE":
 			tok.Type = Like
+		case "ILIKE":
+			tok.Type = ILike
 		case "REGEXP", "RLIKE":
 			tok.Type = RLike
+		case "GLOB":
+			tok.Type = Glob
+		case "CONTAINS":
+			tok.Type = Contains
 		default:
 			tok.Type = Identifier
 		}
@@ -121,17 +129,20 @@ func (t *Tokenizer) Next() *Token {
 	// reading until we reach the matching closing symbol.
 	if t.currentIs('\'', '"', '`') {
 		t.input = t.input[1:]
-		tok.Raw = t.readWord() + t.readUntil(current)
+		tok.Raw = t.readUntil(current)
 		tok.Type = Identifier
 	}
 
-	t.input = t.input[1:]
+	if t.current() != -1 {
+		t.input = t.input[1:]
+	}
 	return t.setToken(tok)
 }
 
 // setToken adds token to the list of this Tokenizer's tokens.
 func (t *Tokenizer) setToken(token *Token) *Token {
 	t.tokens = append(t.tokens, token)
+	t.positions = append(t.positions, t.start)
 	return token
 }
 
@@ -177,10 +188,32 @@ func (t *Tokenizer) currentIs(runes ...rune) bool {
 // readWord reads a single word from the input. Returns when the next rune is
 // any of: nil (-1), empty space, comma, single/double quote, backtick,
 // or opening/closing parenthesis/bracket.
+//
+// So that words may be glob patterns, a bracket inside a word starts a
+// character class (e.g. `*.[ch]`) if it's closed within the word, and commas
+// inside braces (e.g. `{foo,bar}`) don't end the word.
 func (t *Tokenizer) readWord() string {
 	word := []rune{}
+	braces := 0
 
 	for {
+		switch {
+		case t.current() == '[' && len(word) > 0:
+			if end := t.classEnd(); end > 0 {
+				word = append(word, t.input[:end+1]...)
+				t.input = t.input[end+1:]
+				continue
+			}
+		case t.current() == '{':
+			braces++
+		case t.current() == '}' && braces > 0:
+			braces--
+		case t.current() == ',' && braces > 0:
+			word = append(word, ',')
+			t.input = t.input[1:]
+			continue
+		}
+
 		if unicode.IsSpace(t.current()) ||
 			t.currentIs(-1, ',', '\'', '"', '`', '(', ')', '[', ']') {
 			return string(word)
@@ -191,6 +224,24 @@ func (t *Tokenizer) readWord() string {
 	}
 }
 
+// classEnd returns the index of the bracket which closes the character class
+// opened by the current rune, or -1 if the class isn't closed before the end
+// of the word. A closing bracket directly after the opening bracket (or its
+// negation) is part of the class.
+func (t *Tokenizer) classEnd() int {
+	for i := 1; i < len(t.input); i++ {
+		r := t.input[i]
+		if r == ']' && i > 1 && !(i == 2 && (t.input[1] == '!' || t.input[1] == '^')) {
+			return i
+		}
+		if unicode.IsSpace(r) || r == ',' || r == '\'' || r == '"' || r == '`' ||
+			r == '(' || r == ')' || r == '[' {
+			return -1
+		}
+	}
+	return -1
+}
+
 // readQuery reads a full string until reaching a closing parentheses. Counts
 // opening parens to ensure that balance is maintained.
 func (t *Tokenizer) readQuery() string {
@@ -231,14 +282,13 @@ func (t *Tokenizer) readQuery() string {
 	return query
 }
 
-// readUnitl reads the input starting at start, until reaching a rune in runes.
+// readUntil reads the input verbatim, until reaching a rune in runes or the end
+// of the input.
 func (t *Tokenizer) readUntil(runes ...rune) string {
-	var word string
-	for !t.currentIs(runes...) {
-		for unicode.IsSpace(t.current()) {
-			t.input = t.input[1:]
-		}
-		word = fmt.Sprintf("%s %s", word, t.readWord())
+	word := []rune{}
+	for t.current() != -1 && !t.currentIs(runes...) {
+		word = append(word, t.current())
+		t.input = t.input[1:]
 	}
-	return word
+	return string(word)
 }
@@ -22,12 +22,16 @@ func TestTokenizer_NextTokenType(t *testing.T) {
 		{input: "IN", expected: In},
 		{input: "IS", expected: Is},
 		{input: "LIKE", expected: Like},
+		{input: "ILIKE", expected: ILike},
 		{input: "RLIKE", expected: RLike},
+		{input: "GLOB", expected: Glob},
+		{input: "CONTAINS", expected: Contains},
 		{input: "foo", expected: Identifier},
 		{input: "(", expected: OpenParen},
 		{input: ")", expected: CloseParen},
 		{input: ",", expected: Comma},
 		{input: "-", expected: Hyphen},
+		{input: "!", expected: ExclamationMark},
 		{input: "=", expected: Equals},
 		{input: "<>", expected: NotEquals},
 		{input: "<", expected: LessThan},
@@ -51,15 +55,16 @@ func TestTokenizer_NextRaw(t *testing.T) {
 		expected string
 	}
 
-	// TODO: Fix the last 2 cases, they're currently hanging.
 	cases := []Case{
 		{input: "foo", expected: "foo"},
 		{input: " foo ", expected: "foo"},
 		{input: "\" foo \"", expected: " foo "},
 		{input: "' foo '", expected: " foo "},
 		{input: "` foo `", expected: " foo "},
-		// Case{input: "\"foo'bar\"", expected: "foo'bar"},
-		// Case{input: "\"()\"", expected: "()"},
+		{input: "\"foo'bar\"", expected: "foo'bar"},
+		{input: "\"()\"", expected: "()"},
+		{input: "'func\\s+[A-Z](, x)'", expected: "func\\s+[A-Z](, x)"},
+		{input: "'foo", expected: "foo"},
 	}
 
 	for _, c := range cases {
@@ -156,6 +161,14 @@ func TestTokenizer_ReadWord(t *testing.T) {
 		{input: "foo", expected: "foo"},
 		{input: "foo bar", expected: "foo"},
 		{input: "", expected: ""},
+		{input: "src/*.[ch] foo", expected: "src/*.[ch]"},
+		{input: "a[]b]c", expected: "a[]b]c"},
+		{input: "a[!]]", expected: "a[!]]"},
+		{input: "foo[a,b]", expected: "foo"},
+		{input: "foo[ab", expected: "foo"},
+		{input: "{src,lib}/**/*.go, foo", expected: "{src,lib}/**/*.go"},
+		{input: "a{b,{c,d}}e,f", expected: "a{b,{c,d}}e"},
+		{input: "foo,bar", expected: "foo"},
 	}
 
 	for _, c := range cases {
@@ -190,8 +203,12 @@ func TestTokenizer_ReadUntil(t *testing.T) {
 		expected string
 	}
 
-	// TODO: Complete these cases.
-	cases := []Case{}
+	cases := []Case{
+		{input: "foo'", until: []rune{'\''}, expected: "foo"},
+		{input: "foo  bar'", until: []rune{'\''}, expected: "foo  bar"},
+		{input: "(foo, [bar])`", until: []rune{'`'}, expected: "(foo, [bar])"},
+		{input: "foo", until: []rune{'\''}, expected: "foo"},
+	}
 
 	for _, c := range cases {
 		actual := NewTokenizer(c.input).readUntil(c.until...)
@@ -200,3 +217,16 @@ func TestTokenizer_ReadUntil(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenizer_Position(t *testing.T) {
+	tok := NewTokenizer("  name RLIKE 'a(' OR")
+	expected := []int{2, 7, 13, 18}
+	for _, position := range expected {
+		if actual := tok.Position(tok.Next()); actual != position {
+			t.Fatalf("\nExpected: %v\n     Got: %v", position, actual)
+		}
+	}
+	if actual := tok.Position(&Token{}); actual != -1 {
+		t.Fatalf("\nExpected: %v\n     Got: %v", -1, actual)
+	}
+}
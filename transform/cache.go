@@ -0,0 +1,101 @@
+package transform
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HashCache is an on-disk cache of file hashes. Entries are keyed by the
+// device, inode, size, and modification time of each file (along with the
+// hash algorithm and byte limit), so they're invalidated automatically when
+// any of these change.
+type HashCache struct {
+	Dir string
+}
+
+// hashCacheCtxKey is the key of the cache set by WithHashCache.
+type hashCacheCtxKey struct{}
+
+// WithHashCache returns a copy of ctx in which HashFileContext reads hashes
+// from and writes them to c.
+func WithHashCache(ctx context.Context, c *HashCache) context.Context {
+	return context.WithValue(ctx, hashCacheCtxKey{}, c)
+}
+
+// hashCacheFrom returns the cache set by WithHashCache in ctx, or nil if
+// there's none.
+func hashCacheFrom(ctx context.Context) *HashCache {
+	c, _ := ctx.Value(hashCacheCtxKey{}).(*HashCache)
+	return c
+}
+
+// DefaultHashCacheDir returns the default cache directory, i.e.
+// $XDG_CACHE_HOME/fsql on Linux.
+func DefaultHashCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "fsql"), nil
+}
+
+// Purge removes every entry from the cache.
+func (c *HashCache) Purge() error {
+	return os.RemoveAll(filepath.Join(c.Dir, "hashes"))
+}
+
+// get returns the cached value for key, if any.
+func (c *HashCache) get(key string) (string, bool) {
+	b, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// set stores value for key. The cache is best-effort, so errors are ignored.
+func (c *HashCache) set(key, value string) {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+
+	// Write to a temporary file and rename it so that concurrent readers never
+	// see a partially-written entry.
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.WriteString(value)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+// path returns the location of the entry for key.
+func (c *HashCache) path(key string) string {
+	return filepath.Join(c.Dir, "hashes", key[:2], key[2:])
+}
+
+// hashCacheKey returns the cache key for the file described by info. Returns
+// false if the file can't be uniquely identified on this platform.
+func hashCacheKey(info os.FileInfo, name string, limit int64) (string, bool) {
+	dev, ino, ok := FileID(info)
+	if !ok {
+		return "", false
+	}
+	sum := sha1.Sum([]byte(fmt.Sprintf("%d:%d:%d:%d:%s:%d", dev, ino,
+		info.Size(), info.ModTime().UnixNano(), strings.ToUpper(name), limit)))
+	return hex.EncodeToString(sum[:]), true
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package transform
+
+import "os"
+
+// FileID always fails, since we can't cheaply identify files on this platform.
+func FileID(info os.FileInfo) (dev, ino uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,64 @@
+package transform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCache_HashFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	if err := os.WriteFile(path, []byte("foobar"), 0644); err != nil {
+		t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+	}
+
+	cache := &HashCache{Dir: filepath.Join(dir, "cache")}
+	ctx := WithHashCache(context.Background(), cache)
+
+	hash := func() interface{} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+		}
+		value, err := HashFileContext(ctx, info, path, "SHA1", -1)
+		if err != nil {
+			t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+		}
+		return value
+	}
+
+	expected := "8843d7f92416211de9ebb963ff4ce28125932878"
+	if actual := hash(); !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("\nExpected: %s\n     Got: %s", expected, actual)
+	}
+
+	info, _ := os.Stat(path)
+	key, ok := hashCacheKey(info, "SHA1", -1)
+	if !ok {
+		t.Skip("file identity unavailable on this platform")
+	}
+	if value, ok := cache.get(key); !ok || value != expected {
+		t.Fatalf("\nExpected: %s\n     Got: %s", expected, value)
+	}
+
+	// Modifying the file must invalidate the entry.
+	if err := os.WriteFile(path, []byte("foo"), 0644); err != nil {
+		t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+	}
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Hour))
+	expected = "0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33"
+	if actual := hash(); !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("\nExpected: %s\n     Got: %s", expected, actual)
+	}
+
+	if err := cache.Purge(); err != nil {
+		t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+	}
+	if _, ok := cache.get(key); ok {
+		t.Fatalf("\nExpected purged cache\n     Got entry for %s", key)
+	}
+}
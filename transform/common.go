@@ -1,14 +1,41 @@
 package transform
 
 import (
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
+	"fmt"
 	"hash"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
+// hashBufferSize is the size of the buffer used when streaming a file through
+// a hash function.
+const hashBufferSize = 32 << 10
+
+// sizeUnits holds the number of bytes in each size unit, which are used to
+// format and parse sizes (e.g. `FORMAT(size, KB)` or `PREFIX 1MB`).
+var sizeUnits = map[string]float64{
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+}
+
+// sizeUnit returns the number of bytes in the size unit unit (`B`, `KB`, `MB`,
+// or `GB`, case insensitive). ok is false if unit isn't a size unit.
+func sizeUnit(unit string) (bytes float64, ok bool) {
+	bytes, ok = sizeUnits[strings.ToUpper(strings.TrimSpace(unit))]
+	return bytes, ok
+}
+
 // formatName runs the correct name format function based on the value of arg.
 func formatName(arg, name string) interface{} {
 	switch strings.ToUpper(arg) {
@@ -49,34 +76,272 @@ func FindHash(name string) func() hash.Hash {
 	return nil
 }
 
-// ComputeHash applies the hash h to the file located at path. Returns a line
-// of dashes for directories.
-func ComputeHash(info os.FileInfo, path string, h hash.Hash) (interface{}, error) {
-	fallback := strings.Repeat("-", h.Size()*2)
+// ParseHashArgs parses the arguments of a hash modifier, e.g. the `7, PREFIX
+// 1MB` in `SHA1(hash, 7, PREFIX 1MB)`. Returns the number of digits to keep (-1
+// for all) and the number of leading bytes to hash (-1 for the whole file).
+func ParseHashArgs(args []string) (n int, limit int64, err error) {
+	n, limit = defaultHashLength, -1
 
-	// If the current file is a symlink, attempt to evaluate the link and
-	// stat the resultant file. If either process fails, ignore the error and
-	// return the fallback.
-	if info.Mode()&os.ModeSymlink == os.ModeSymlink {
-		var err error
-		if path, err = filepath.EvalSymlinks(path); err != nil {
-			return fallback, nil
+	for i := 0; i < len(args); i++ {
+		arg := strings.TrimSpace(args[i])
+		if arg == "" {
+			continue
 		}
-		if info, err = os.Stat(path); err != nil {
-			return fallback, nil
+
+		// The prefix may be provided as a single argument (`'PREFIX 1MB'`) or
+		// split across several (`PREFIX 1MB`, `PREFIX 1 MB`).
+		if fields := strings.Fields(arg); strings.ToUpper(fields[0]) == "PREFIX" {
+			size := strings.Join(fields[1:], "")
+			for j := i + 1; j < len(args); j++ {
+				if _, ok := sizeUnit(args[j]); size != "" && !ok {
+					break
+				}
+				size += strings.TrimSpace(args[j])
+				i = j
+			}
+			if limit, err = ParseSize(size); err != nil {
+				return 0, 0, err
+			}
+			continue
+		}
+
+		if strings.ToUpper(arg) == "FULL" {
+			n = -1
+		} else if n, err = strconv.Atoi(arg); err != nil {
+			return 0, 0, err
 		}
 	}
 
-	if info.IsDir() {
+	return n, limit, nil
+}
+
+// ParseSize parses a size with an optional unit (see sizeUnit) and returns
+// the number of bytes. Sizes without a unit are treated as bytes.
+func ParseSize(s string) (int64, error) {
+	number, unit := strings.TrimSpace(s), "B"
+	if i := strings.IndexFunc(number, func(r rune) bool {
+		return r != '.' && (r < '0' || r > '9')
+	}); i >= 0 {
+		number, unit = strings.TrimSpace(number[:i]), number[i:]
+	}
+
+	bytes, ok := sizeUnit(unit)
+	size, err := strconv.ParseFloat(number, 64)
+	if !ok || err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(size * bytes), nil
+}
+
+// ComputeHash applies the hash h to the file located at path. Returns a line
+// of dashes for directories.
+func ComputeHash(info os.FileInfo, path string, h hash.Hash) (interface{}, error) {
+	return ComputePartialHash(info, path, h, -1)
+}
+
+// ComputePartialHash applies the hash h to the first limit bytes of the file
+// located at path. If limit is negative, the whole file is hashed. The file
+// is streamed through h, so memory usage is bounded regardless of file size.
+func ComputePartialHash(info os.FileInfo, path string, h hash.Hash, limit int64) (interface{}, error) {
+	return computePartialHash(context.Background(), info, path, h, limit)
+}
+
+// computePartialHash is ComputePartialHash, which stops reading the file once
+// ctx is done.
+func computePartialHash(ctx context.Context, info os.FileInfo, path string,
+	h hash.Hash, limit int64) (interface{}, error) {
+	fallback := strings.Repeat("-", h.Size()*2)
+
+	info, path, ok := resolveFile(info, path)
+	if !ok {
 		return fallback, nil
 	}
 
-	b, err := os.ReadFile(path)
+	f, err := openFile(info, path)
 	if err != nil {
 		return nil, err
 	}
-	if _, err := h.Write(b); err != nil {
+	defer f.Close()
+
+	var r io.Reader = newContextReader(ctx, f)
+	if limit >= 0 {
+		r = io.LimitReader(r, limit)
+	}
+	if _, err := io.CopyBuffer(h, r, make([]byte, hashBufferSize)); err != nil {
 		return nil, err
 	}
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
+
+// HashFile applies the hash algorithm name to the first limit bytes of the file
+// located at path (or the whole file, if limit is negative).
+func HashFile(info os.FileInfo, path, name string, limit int64) (interface{}, error) {
+	return HashFileContext(context.Background(), info, path, name, limit)
+}
+
+// HashFileContext is HashFile, which stops reading the file (and returns
+// ctx.Err()) once ctx is done. If ctx has a cache (see WithHashCache),
+// results are read from and written to it.
+func HashFileContext(ctx context.Context, info os.FileInfo, path, name string,
+	limit int64) (interface{}, error) {
+	hashFunc := FindHash(name)
+	if hashFunc == nil {
+		return nil, fmt.Errorf("unexpected hash algorithm %s", name)
+	}
+
+	memo, _ := ctx.Value(hashMemoKey{}).(*hashMemo)
+	memoKey := hashMemoEntry{path: path, name: strings.ToUpper(name), limit: limit}
+	if value, ok := memo.get(memoKey); ok {
+		return value, nil
+	}
+	value, err := hashFile(ctx, info, path, name, hashFunc, limit)
+	if err != nil {
+		return nil, err
+	}
+	memo.set(memoKey, value)
+	return value, nil
+}
+
+// hashFile is HashFileContext, without the memo of ctx.
+func hashFile(ctx context.Context, info os.FileInfo, path, name string,
+	hashFunc func() hash.Hash, limit int64) (interface{}, error) {
+	cache := hashCacheFrom(ctx)
+	if cache == nil {
+		return computePartialHash(ctx, info, path, hashFunc(), limit)
+	}
+
+	resolved, resolvedPath, ok := resolveFile(info, path)
+	if !ok {
+		return computePartialHash(ctx, info, path, hashFunc(), limit)
+	}
+	key, ok := hashCacheKey(resolved, name, limit)
+	if !ok {
+		return computePartialHash(ctx, resolved, resolvedPath, hashFunc(), limit)
+	}
+	if value, ok := cache.get(key); ok {
+		return value, nil
+	}
+
+	value, err := computePartialHash(ctx, resolved, resolvedPath, hashFunc(), limit)
+	if err != nil {
+		return nil, err
+	}
+	cache.set(key, value.(string))
+	return value, nil
+}
+
+// hashMemoKey is the key of the memo set by WithHashMemo.
+type hashMemoKey struct{}
+
+// hashMemo holds the hashes computed with a context. A nil *hashMemo
+// remembers nothing.
+type hashMemo struct {
+	mu     sync.Mutex
+	hashes map[hashMemoEntry]interface{}
+}
+
+// hashMemoEntry identifies a hash in a hashMemo.
+type hashMemoEntry struct {
+	path, name string
+	limit      int64
+}
+
+// WithHashMemo returns a copy of ctx in which each hash computed by
+// HashFileContext is remembered, so that hashing a file again (e.g. in both
+// the WHERE and SELECT clauses of a query) doesn't read it again.
+func WithHashMemo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, hashMemoKey{}, &hashMemo{})
+}
+
+func (m *hashMemo) get(key hashMemoEntry) (interface{}, bool) {
+	if m == nil {
+		return nil, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.hashes[key]
+	return value, ok
+}
+
+func (m *hashMemo) set(key hashMemoEntry, value interface{}) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hashes == nil {
+		m.hashes = make(map[hashMemoEntry]interface{})
+	}
+	m.hashes[key] = value
+}
+
+// readCounterKey is the key of the counter set by WithReadCounter.
+type readCounterKey struct{}
+
+// WithReadCounter returns a copy of ctx in which the number of bytes read from
+// files to compute their attributes (e.g. to hash them, search or count their
+// content, or detect their type) is added to *n (atomically, so that n may be
+// shared by concurrent reads).
+func WithReadCounter(ctx context.Context, n *int64) context.Context {
+	return context.WithValue(ctx, readCounterKey{}, n)
+}
+
+// contextReader reads from r until ctx is done, after which each read returns
+// ctx.Err(). This allows reading a large file to be interrupted. The number
+// of bytes read is added to n, if it's non-nil.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+	n   *int64
+}
+
+// newContextReader returns a contextReader for r, which counts the bytes read
+// with the counter of ctx (see WithReadCounter), if any.
+func newContextReader(ctx context.Context, r io.Reader) *contextReader {
+	n, _ := ctx.Value(readCounterKey{}).(*int64)
+	return &contextReader{ctx: ctx, r: r, n: n}
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := r.r.Read(p)
+	if r.n != nil {
+		atomic.AddInt64(r.n, int64(n))
+	}
+	return n, err
+}
+
+// resolveFile follows info and path to a regular file. If the file is a
+// symlink, the link is evaluated and the resultant file is stat'd. Returns
+// false if this fails, or if the file is a directory.
+func resolveFile(info os.FileInfo, path string) (os.FileInfo, string, bool) {
+	if fi, ok := info.(*FSFileInfo); ok && fi.Mode()&os.ModeSymlink != 0 {
+		// Not all filesystems follow links in Stat (archives don't), so the
+		// result may still be a symlink.
+		target, err := fs.Stat(fi.FS, fi.FSPath)
+		if err != nil || target.Mode()&os.ModeSymlink != 0 {
+			return nil, "", false
+		}
+		info = &FSFileInfo{
+			FileInfo:  target,
+			FS:        fi.FS,
+			FSPath:    fi.FSPath,
+			Container: fi.Container,
+		}
+	} else if info.Mode()&os.ModeSymlink == os.ModeSymlink {
+		var err error
+		if path, err = filepath.EvalSymlinks(path); err != nil {
+			return nil, "", false
+		}
+		if info, err = os.Stat(path); err != nil {
+			return nil, "", false
+		}
+	}
+
+	if info.IsDir() {
+		return nil, "", false
+	}
+	return info, path, true
+}
@@ -1,9 +1,11 @@
 package transform
 
 import (
+	"context"
 	"crypto/sha1"
 	"hash"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -142,3 +144,158 @@ func TestCommon_ComputeHash(t *testing.T) {
 		}
 	}
 }
+
+func TestCommon_ComputePartialHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte("foobar"), 0644); err != nil {
+		t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+	}
+
+	type Case struct {
+		limit    int64
+		expected string
+	}
+
+	cases := []Case{
+		{limit: -1, expected: "8843d7f92416211de9ebb963ff4ce28125932878"},
+		{limit: 3, expected: "0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33"},
+		{limit: 100, expected: "8843d7f92416211de9ebb963ff4ce28125932878"},
+	}
+
+	for _, c := range cases {
+		actual, err := ComputePartialHash(info, path, sha1.New(), c.limit)
+		if err != nil {
+			t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+		}
+		if !reflect.DeepEqual(c.expected, actual) {
+			t.Fatalf("\nExpected: %s\n     Got: %s", c.expected, actual)
+		}
+	}
+}
+
+func TestCommon_ParseSize(t *testing.T) {
+	type Expected struct {
+		size int64
+		err  bool
+	}
+
+	type Case struct {
+		input    string
+		expected Expected
+	}
+
+	cases := []Case{
+		{input: "512", expected: Expected{size: 512}},
+		{input: "512B", expected: Expected{size: 512}},
+		{input: "1.5 KB", expected: Expected{size: 1536}},
+		{input: " 2mb ", expected: Expected{size: 2 << 20}},
+		{input: "1GB", expected: Expected{size: 1 << 30}},
+		{input: "1TB", expected: Expected{err: true}},
+		{input: "KB", expected: Expected{err: true}},
+		{input: "-1", expected: Expected{err: true}},
+		{input: "", expected: Expected{err: true}},
+	}
+
+	for _, c := range cases {
+		size, err := ParseSize(c.input)
+		if (err != nil) != c.expected.err {
+			t.Fatalf("%q\nExpected error: %v\n     Got: %v", c.input, c.expected.err, err)
+		}
+		if size != c.expected.size {
+			t.Fatalf("%q\nExpected: %v\n     Got: %v", c.input, c.expected.size, size)
+		}
+	}
+}
+
+func TestCommon_ParseHashArgs(t *testing.T) {
+	type Expected struct {
+		n     int
+		limit int64
+		err   bool
+	}
+
+	type Case struct {
+		args     []string
+		expected Expected
+	}
+
+	cases := []Case{
+		{args: []string{}, expected: Expected{n: 7, limit: -1}},
+		{args: []string{""}, expected: Expected{n: 7, limit: -1}},
+		{args: []string{"20"}, expected: Expected{n: 20, limit: -1}},
+		{args: []string{"FULL"}, expected: Expected{n: -1, limit: -1}},
+		{args: []string{"7", "PREFIX", "1MB"}, expected: Expected{n: 7, limit: 1 << 20}},
+		{args: []string{"PREFIX", "2", "kb"}, expected: Expected{n: 7, limit: 2 << 10}},
+		{args: []string{"full", "prefix 512"}, expected: Expected{n: -1, limit: 512}},
+		{args: []string{"PREFIX", "lots"}, expected: Expected{err: true}},
+		{args: []string{"foo"}, expected: Expected{err: true}},
+	}
+
+	for _, c := range cases {
+		n, limit, err := ParseHashArgs(c.args)
+		if c.expected.err {
+			if err == nil {
+				t.Fatalf("\nExpected error for %v\n     Got: nil", c.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+		}
+		if n != c.expected.n || limit != c.expected.limit {
+			t.Fatalf("\nExpected: %d, %d\n     Got: %d, %d", c.expected.n,
+				c.expected.limit, n, limit)
+		}
+	}
+}
+
+func TestCommon_HashFileContext(t *testing.T) {
+	path := "../testdata/baz"
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := HashFileContext(ctx, info, path, "SHA1", -1); err != context.Canceled {
+		t.Fatalf("\nExpected: %v\n     Got: %v", context.Canceled, err)
+	}
+}
+
+func TestCommon_WithReadCounter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "foo")
+	if err := os.WriteFile(path, []byte("foo bar baz"), 0644); err != nil {
+		t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+	}
+
+	type Case struct {
+		limit    int64
+		expected int64
+	}
+
+	cases := []Case{
+		{limit: -1, expected: 11},
+		{limit: 2, expected: 2},
+		{limit: 0, expected: 0},
+	}
+
+	for _, c := range cases {
+		var n int64
+		ctx := WithReadCounter(context.Background(), &n)
+		if _, err := computePartialHash(ctx, info, path, sha1.New(), c.limit); err != nil {
+			t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+		}
+		if n != c.expected {
+			t.Fatalf("\nExpected: %d\n     Got: %d", c.expected, n)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package transform
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultMaxContentSize is the default maximum number of bytes read from
+// each file when searching its contents.
+const DefaultMaxContentSize = 10 << 20
+
+// binarySniffSize is the number of leading bytes inspected when deciding if
+// a file is binary.
+const binarySniffSize = 8000
+
+// ContentOptions controls how file contents are read by content conditions
+// and modifiers.
+type ContentOptions struct {
+	// MaxSize is the maximum number of bytes read from each file. Anything
+	// past this point is ignored. Use a negative value to read entire files.
+	MaxSize int64
+	// Binary denotes whether binary files are searched. If false, binary files
+	// never match.
+	Binary bool
+}
+
+// contentOptionsKey is the key of the options set by WithContentOptions.
+type contentOptionsKey struct{}
+
+// WithContentOptions returns a copy of ctx in which file contents are read
+// with the options o. Otherwise, up to DefaultMaxContentSize bytes of each
+// file are read, and binary files are skipped.
+func WithContentOptions(ctx context.Context, o ContentOptions) context.Context {
+	return context.WithValue(ctx, contentOptionsKey{}, o)
+}
+
+// contentOptions returns the options set by WithContentOptions in ctx, or the
+// defaults if there are none.
+func contentOptions(ctx context.Context) ContentOptions {
+	if o, ok := ctx.Value(contentOptionsKey{}).(ContentOptions); ok {
+		return o
+	}
+	return ContentOptions{MaxSize: DefaultMaxContentSize}
+}
+
+// contentReader is a buffered reader over the contents of a single file.
+type contentReader struct {
+	*bufio.Reader
+	f fs.File
+}
+
+func (r *contentReader) Close() error { return r.f.Close() }
+
+// openContent opens the file located at path for reading, up to the maximum
+// content size. Returns nil if the file can't be searched, i.e. if it's a
+// directory or (unless enabled) a binary file. Reading stops once ctx is done.
+func openContent(ctx context.Context, info os.FileInfo, path string) (*contentReader, error) {
+	info, path, ok := resolveFile(info, path)
+	if !ok {
+		return nil, nil
+	}
+
+	f, err := openFile(info, path)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := contentOptions(ctx)
+	var r io.Reader = newContextReader(ctx, f)
+	if opts.MaxSize >= 0 {
+		r = io.LimitReader(r, opts.MaxSize)
+	}
+	cr := &contentReader{Reader: bufio.NewReaderSize(r, binarySniffSize), f: f}
+
+	if !opts.Binary {
+		head, err := cr.Peek(binarySniffSize)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			f.Close()
+			return nil, err
+		}
+		if IsBinary(head) {
+			f.Close()
+			return nil, nil
+		}
+	}
+	return cr, nil
+}
+
+// IsBinary reports whether b (the leading bytes of a file) looks like binary
+// data. Like git, we consider any file containing a NUL byte to be binary.
+func IsBinary(b []byte) bool {
+	return bytes.IndexByte(b, 0) != -1
+}
+
+// ContentContains reports whether the contents of the file located at path
+// contain substr.
+func ContentContains(info os.FileInfo, path, substr string) (bool, error) {
+	return ContentContainsContext(context.Background(), info, path, substr)
+}
+
+// ContentContainsContext is ContentContains, which stops reading the file
+// (and returns ctx.Err()) once ctx is done.
+func ContentContainsContext(ctx context.Context, info os.FileInfo, path,
+	substr string) (bool, error) {
+	r, err := openContent(ctx, info, path)
+	if r == nil || err != nil {
+		return false, err
+	}
+	defer r.Close()
+
+	if substr == "" {
+		return true, nil
+	}
+
+	// Read the file in chunks, carrying over the last len(substr)-1 bytes of
+	// each chunk so that matches spanning two chunks aren't missed.
+	needle := []byte(substr)
+	buf := make([]byte, 0, hashBufferSize+len(needle))
+	chunk := make([]byte, hashBufferSize)
+	for {
+		n, err := r.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if bytes.Contains(buf, needle) {
+			return true, nil
+		}
+		if keep := len(needle) - 1; len(buf) > keep {
+			buf = append(buf[:0], buf[len(buf)-keep:]...)
+		}
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// ContentMatches reports whether the contents of the file located at path
+// match re.
+func ContentMatches(info os.FileInfo, path string, re *regexp.Regexp) (bool, error) {
+	return ContentMatchesContext(context.Background(), info, path, re)
+}
+
+// ContentMatchesContext is ContentMatches, which stops reading the file (and
+// returns ctx.Err()) once ctx is done.
+func ContentMatchesContext(ctx context.Context, info os.FileInfo, path string,
+	re *regexp.Regexp) (bool, error) {
+	r, err := openContent(ctx, info, path)
+	if r == nil || err != nil {
+		return false, err
+	}
+	defer r.Close()
+	matched := re.MatchReader(r)
+	// MatchReader stops at the first read error, so a cancelled search isn't
+	// mistaken for a mismatch.
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return matched, nil
+}
+
+// MatchingLines returns each line of the file located at path that matches
+// re, prefixed by its line number.
+func MatchingLines(info os.FileInfo, path string, re *regexp.Regexp) ([]string, error) {
+	return MatchingLinesContext(context.Background(), info, path, re)
+}
+
+// MatchingLinesContext is MatchingLines, which stops reading the file (and
+// returns ctx.Err()) once ctx is done.
+func MatchingLinesContext(ctx context.Context, info os.FileInfo, path string,
+	re *regexp.Regexp) ([]string, error) {
+	lines := make([]string, 0)
+
+	r, err := openContent(ctx, info, path)
+	if r == nil || err != nil {
+		return lines, err
+	}
+	defer r.Close()
+
+	for n := 1; ; n++ {
+		line, err := r.ReadString('\n')
+		// Skip the empty "line" following a trailing newline.
+		if !(err == io.EOF && line == "") {
+			line = strings.TrimRight(line, "\r\n")
+			if re.MatchString(line) {
+				lines = append(lines, fmt.Sprintf("%d:%s", n, line))
+			}
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Counts holds the number of lines, words, and characters in a file.
+type Counts struct {
+	Lines, Words, Chars int64
+}
+
+// CountContent counts the lines, words, and characters (runes) in the file
+// located at path, the same way as `wc -lwm`. Unlike the other content
+// functions, the whole file is always read. Returns nil for directories and
+// binary files.
+func CountContent(info os.FileInfo, path string) (*Counts, error) {
+	return CountContentContext(context.Background(), info, path)
+}
+
+// CountContentContext is CountContent, which stops reading the file (and
+// returns ctx.Err()) once ctx is done.
+func CountContentContext(ctx context.Context, info os.FileInfo, path string) (*Counts, error) {
+	info, path, ok := resolveFile(info, path)
+	if !ok {
+		return nil, nil
+	}
+
+	f, err := openFile(info, path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(newContextReader(ctx, f), binarySniffSize)
+	head, err := r.Peek(binarySniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	if IsBinary(head) {
+		return nil, nil
+	}
+
+	counts := &Counts{}
+	inWord := false
+	for {
+		c, size, err := r.ReadRune()
+		if err == io.EOF {
+			return counts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if c == utf8.RuneError && size == 1 {
+			// Invalid UTF-8 bytes aren't counted as characters.
+			continue
+		}
+
+		counts.Chars++
+		if c == '\n' {
+			counts.Lines++
+		}
+		if unicode.IsSpace(c) {
+			inWord = false
+		} else if !inWord {
+			inWord = true
+			counts.Words++
+		}
+	}
+}
+
+// CompileContentPattern compiles a pattern used to search file contents. ^
+// and $ match at the beginning and end of each line.
+func CompileContentPattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?m)" + pattern)
+}
@@ -0,0 +1,145 @@
+package transform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeContentFile(t *testing.T, content string) (os.FileInfo, string) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+	}
+	return info, path
+}
+
+func TestContent_ContentContains(t *testing.T) {
+	type Case struct {
+		content  string
+		substr   string
+		expected bool
+	}
+
+	// Ensure that matches spanning two chunks are found.
+	long := strings.Repeat("a", hashBufferSize-2) + "TODO"
+
+	cases := []Case{
+		{content: "foo\nTODO: bar\n", substr: "TODO", expected: true},
+		{content: "foo\nbar\n", substr: "TODO", expected: false},
+		{content: long, substr: "TODO", expected: true},
+		{content: "foo\x00TODO", substr: "TODO", expected: false},
+	}
+
+	for _, c := range cases {
+		info, path := writeContentFile(t, c.content)
+		actual, err := ContentContains(info, path, c.substr)
+		if err != nil {
+			t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+		}
+		if actual != c.expected {
+			t.Fatalf("\nExpected: %v\n     Got: %v", c.expected, actual)
+		}
+	}
+}
+
+func TestContent_ContentOptions(t *testing.T) {
+	info, path := writeContentFile(t, "foo\x00TODO")
+	if ok, _ := ContentContains(info, path, "TODO"); ok {
+		t.Fatalf("\nExpected: false\n     Got: true")
+	}
+
+	ctx := WithContentOptions(context.Background(), ContentOptions{MaxSize: -1, Binary: true})
+	if ok, _ := ContentContainsContext(ctx, info, path, "TODO"); !ok {
+		t.Fatalf("\nExpected: true\n     Got: false")
+	}
+
+	ctx = WithContentOptions(context.Background(), ContentOptions{MaxSize: 4, Binary: true})
+	if ok, _ := ContentContainsContext(ctx, info, path, "TODO"); ok {
+		t.Fatalf("\nExpected: false\n     Got: true")
+	}
+}
+
+func TestContent_MatchingLines(t *testing.T) {
+	info, path := writeContentFile(t, "package main\n\nfunc main() {\n}\nfunc  foo()\n")
+	re, err := CompileContentPattern(`^func\s+\w+`)
+	if err != nil {
+		t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+	}
+
+	if ok, err := ContentMatches(info, path, re); err != nil || !ok {
+		t.Fatalf("\nExpected: true\n     Got: %v, %v", ok, err)
+	}
+
+	expected := []string{"3:func main() {", "5:func  foo()"}
+	actual, err := MatchingLines(info, path, re)
+	if err != nil {
+		t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("\nExpected: %v\n     Got: %v", expected, actual)
+	}
+}
+
+func TestContent_CountContent(t *testing.T) {
+	type Case struct {
+		content  string
+		expected *Counts
+	}
+
+	cases := []Case{
+		{content: "", expected: &Counts{}},
+		{content: "foo bar\nbaz\n", expected: &Counts{Lines: 2, Words: 3, Chars: 12}},
+		{content: "  héllo  wörld", expected: &Counts{Lines: 0, Words: 2, Chars: 14}},
+		{content: "foo\x00bar", expected: nil},
+	}
+
+	for _, c := range cases {
+		info, path := writeContentFile(t, c.content)
+		actual, err := CountContent(info, path)
+		if err != nil {
+			t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+		}
+		if !reflect.DeepEqual(c.expected, actual) {
+			t.Fatalf("\nExpected: %v\n     Got: %v", c.expected, actual)
+		}
+	}
+
+	info, err := os.Stat("../testdata/foo")
+	if err != nil {
+		t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+	}
+	if actual, err := CountContent(info, "../testdata/foo"); err != nil || actual != nil {
+		t.Fatalf("\nExpected: nil, nil\n     Got: %v, %v", actual, err)
+	}
+}
+
+func TestContent_Context(t *testing.T) {
+	info, path := writeContentFile(t, "foo bar\nbaz\n")
+	re, err := CompileContentPattern("foo")
+	if err != nil {
+		t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := CountContentContext(ctx, info, path); err != context.Canceled {
+		t.Fatalf("\nExpected: %v\n     Got: %v", context.Canceled, err)
+	}
+	if _, err := MatchingLinesContext(ctx, info, path, re); err != context.Canceled {
+		t.Fatalf("\nExpected: %v\n     Got: %v", context.Canceled, err)
+	}
+	for _, attribute := range []string{"hash", "lines", "words", "chars"} {
+		if _, err := DefaultFormatValueContext(ctx, attribute, path, info); err != context.Canceled {
+			t.Fatalf("\nExpected: %v\n     Got: %v", context.Canceled, err)
+		}
+	}
+}
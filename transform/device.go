@@ -0,0 +1,38 @@
+package transform
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Device returns the device containing the file described by info, formatted
+// as `major:minor`, or nil if it's unknown (e.g. for a file in an archive).
+func Device(info os.FileInfo) interface{} {
+	dev, _, ok := FileID(info)
+	if !ok {
+		return nil
+	}
+	major, minor := splitDevice(dev)
+	return fmt.Sprintf("%d:%d", major, minor)
+}
+
+var (
+	mountsOnce sync.Once
+	mounts     map[string]string
+)
+
+// FSType returns the type of the filesystem containing the file described by
+// info (e.g. `ext4` or `nfs`), or nil if it's unknown. Filesystem types are
+// read from the mount table once per process.
+func FSType(info os.FileInfo) interface{} {
+	device, ok := Device(info).(string)
+	if !ok {
+		return nil
+	}
+	mountsOnce.Do(func() { mounts = readMounts() })
+	if fstype, ok := mounts[device]; ok {
+		return fstype
+	}
+	return nil
+}
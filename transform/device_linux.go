@@ -0,0 +1,53 @@
+package transform
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// splitDevice returns the major and minor numbers of the device number dev.
+func splitDevice(dev uint64) (major, minor uint64) {
+	major = (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor = dev&0xff | (dev>>12)&^0xff
+	return major, minor
+}
+
+// readMounts returns the type of each mounted filesystem, keyed by its device
+// (as `major:minor`), from /proc/self/mountinfo.
+func readMounts() map[string]string {
+	result := make(map[string]string)
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return result
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		device, fstype, ok := parseMountInfo(scanner.Text())
+		if _, seen := result[device]; ok && !seen {
+			result[device] = fstype
+		}
+	}
+	return result
+}
+
+// parseMountInfo returns the device and filesystem type of a line of
+// /proc/self/mountinfo, which is of the form:
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw
+//
+// where the optional fields (`master:1`) are terminated by a hyphen.
+func parseMountInfo(line string) (device, fstype string, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return "", "", false
+	}
+	for i := 6; i+1 < len(fields); i++ {
+		if fields[i] == "-" {
+			return fields[2], fields[i+1], true
+		}
+	}
+	return "", "", false
+}
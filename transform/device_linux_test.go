@@ -0,0 +1,64 @@
+package transform
+
+import (
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDevice_ParseMountInfo(t *testing.T) {
+	type Case struct {
+		input  string
+		device string
+		fstype string
+		ok     bool
+	}
+
+	cases := []Case{
+		{
+			input:  "36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue",
+			device: "98:0",
+			fstype: "ext3",
+			ok:     true,
+		},
+		{
+			input:  "22 1 0:21 / /proc rw,nosuid - proc proc rw",
+			device: "0:21",
+			fstype: "proc",
+			ok:     true,
+		},
+		{input: "22 1 0:21 / /proc rw,nosuid", ok: false},
+		{input: "", ok: false},
+	}
+
+	for _, c := range cases {
+		device, fstype, ok := parseMountInfo(c.input)
+		if device != c.device || fstype != c.fstype || ok != c.ok {
+			t.Fatalf("\nExpected %s %s %v\n     Got %s %s %v", c.device, c.fstype, c.ok,
+				device, fstype, ok)
+		}
+	}
+}
+
+func TestDevice_SplitDevice(t *testing.T) {
+	// makedev(259, 65537)
+	major, minor := splitDevice(0x10010301)
+	if major != 259 || minor != 65537 {
+		t.Fatalf("\nExpected 259:65537\n     Got %d:%d", major, minor)
+	}
+}
+
+func TestDevice_FSType(t *testing.T) {
+	info, err := os.Stat("/proc/self")
+	if err != nil {
+		t.Skipf("/proc isn't available: %v", err)
+	}
+	if fstype := FSType(info); fstype != "proc" {
+		t.Fatalf("\nExpected proc\n     Got %v", fstype)
+	}
+	info, _ = fs.Stat(fstest.MapFS{"a": {}}, "a")
+	if device := Device(info); device != nil {
+		t.Fatalf("\nExpected NULL\n     Got %v", device)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package transform
+
+// splitDevice returns the device number dev as the major number, since the
+// encoding of device numbers differs between platforms.
+func splitDevice(dev uint64) (major, minor uint64) {
+	return dev, 0
+}
+
+// readMounts returns an empty mount table, since filesystem types are only
+// read on Linux.
+func readMounts() map[string]string {
+	return map[string]string{}
+}
@@ -1,10 +1,10 @@
 package transform
 
 import (
+	"context"
 	"fmt"
-	"hash"
 	"os"
-	"strconv"
+	"regexp"
 	"strings"
 	"time"
 )
@@ -20,6 +20,22 @@ type FormatParams struct {
 
 	Name string
 	Args []string
+
+	// Pattern is the compiled pattern of Args, for modifiers which use one
+	// (see CompileModifier). If nil, the pattern is compiled when it's needed.
+	Pattern *regexp.Regexp
+
+	// Context interrupts reading the file (e.g. while hashing it). If nil,
+	// the file is always read in full.
+	Context context.Context
+}
+
+// context returns the Context of p, or context.Background() if it's nil.
+func (p *FormatParams) context() context.Context {
+	if p.Context == nil {
+		return context.Background()
+	}
+	return p.Context
 }
 
 // Format runs the respective format function on the provided parameters.
@@ -27,16 +43,25 @@ func Format(p *FormatParams) (val interface{}, err error) {
 	switch strings.ToUpper(p.Name) {
 	case "FORMAT":
 		val, err = p.format()
-	case "UPPER":
-		val = upper(p.Value.(string))
-	case "LOWER":
-		val = lower(p.Value.(string))
+	case "UPPER", "LOWER":
+		if p.Value == nil {
+			// A NULL value (e.g. the imgformat of a file that isn't an
+			// image) stays NULL.
+			return nil, nil
+		}
+		if s, ok := p.Value.(string); ok && strings.ToUpper(p.Name) == "UPPER" {
+			val = upper(s)
+		} else if ok {
+			val = lower(s)
+		}
 	case "FULLPATH":
 		val, err = p.fullPath()
 	case "SHORTPATH":
 		val, err = p.shortPath()
 	case "SHA1":
-		val, err = p.hash(FindHash(p.Name)())
+		val, err = p.hash()
+	case "MATCHES":
+		val, err = p.matches()
 	}
 	if err != nil {
 		return nil, err
@@ -47,6 +72,17 @@ func Format(p *FormatParams) (val interface{}, err error) {
 	return val, nil
 }
 
+// ReadsFile reports whether the modifier name computes its value by reading
+// the file itself (e.g. SHA1), rather than from the attribute's value, in which
+// case the attribute's default format value isn't needed.
+func ReadsFile(name string) bool {
+	switch strings.ToUpper(name) {
+	case "SHA1", "MATCHES":
+		return true
+	}
+	return false
+}
+
 // format runs a format function based on the value of the provided attribute.
 func (p *FormatParams) format() (val interface{}, err error) {
 	switch p.Attribute {
@@ -66,19 +102,15 @@ func (p *FormatParams) format() (val interface{}, err error) {
 	return val, nil
 }
 
-// formatSize formats a size. Valid arguments include `KB`, `MB`, `GB` (case
-// insensitive).
+// formatSize formats a size. Valid arguments include `B`, `KB`, `MB`, `GB`
+// (case insensitive).
 func (p *FormatParams) formatSize() (interface{}, error) {
-	size := p.Value.(int64)
-	switch strings.ToUpper(p.Args[0]) {
-	case "KB":
-		return fmt.Sprintf("%fkb", float64(size)/(1<<10)), nil
-	case "MB":
-		return fmt.Sprintf("%fmb", float64(size)/(1<<20)), nil
-	case "GB":
-		return fmt.Sprintf("%fgb", float64(size)/(1<<30)), nil
-	}
-	return nil, nil
+	bytes, ok := sizeUnit(p.Args[0])
+	if !ok {
+		return nil, nil
+	}
+	return fmt.Sprintf("%f%s", float64(p.Value.(int64))/bytes,
+		strings.ToLower(strings.TrimSpace(p.Args[0]))), nil
 }
 
 // formatTime formats a time. Valid arguments include `UNIX` and `ISO` (case
@@ -113,45 +145,127 @@ func (p *FormatParams) shortPath() (interface{}, error) {
 	return p.Info.Name(), nil
 }
 
-// hash applies the provided hash algorithm h with ComputeHash.
-func (p *FormatParams) hash(h hash.Hash) (interface{}, error) {
-	var (
-		err    error
-		n      int
-		result interface{}
-	)
-
-	if len(p.Args) == 0 || p.Args[0] == "" {
-		n = defaultHashLength
-	} else if strings.ToUpper(p.Args[0]) == "FULL" {
-		n = -1
-	} else if n, err = strconv.Atoi(p.Args[0]); err != nil {
+// hash applies the hash algorithm p.Name with HashFile.
+func (p *FormatParams) hash() (interface{}, error) {
+	n, limit, err := ParseHashArgs(p.Args)
+	if err != nil {
 		return nil, err
 	}
 
-	if result, err = ComputeHash(p.Info, p.Path, h); err != nil {
+	result, err := HashFileContext(p.context(), p.Info, p.Path, p.Name, limit)
+	if err != nil {
 		return nil, err
 	}
 
 	return truncate(result.(string), n), nil
 }
 
+// CompileModifier compiles the pattern of the modifier name with the
+// arguments args, i.e. the pattern of MATCHES (see CompileContentPattern).
+// Returns nil if the modifier doesn't use a pattern.
+//
+// The result may be passed through FormatParams.Pattern, so that the pattern
+// is compiled once per query rather than once per file.
+func CompileModifier(name string, args []string) (*regexp.Regexp, error) {
+	if strings.ToUpper(name) != "MATCHES" {
+		return nil, nil
+	}
+	if len(args) == 0 || args[0] == "" {
+		return nil, fmt.Errorf("function MATCHES expects a pattern")
+	}
+	return CompileContentPattern(strings.Join(args, " "))
+}
+
+// matches returns each line of the current file that matches the pattern in
+// p.Args, prefixed by its line number. Only supports the `content` attribute.
+func (p *FormatParams) matches() (interface{}, error) {
+	if p.Attribute != "content" {
+		return nil, nil
+	}
+	re := p.Pattern
+	if re == nil {
+		var err error
+		if re, err = CompileModifier(p.Name, p.Args); err != nil {
+			return nil, err
+		}
+	}
+	lines, err := MatchingLinesContext(p.context(), p.Info, p.Path, re)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Join(lines, "; "), nil
+}
+
 // DefaultFormatValue returns the default format value for the provided
 // attribute attr based on path and info.
-func DefaultFormatValue(attr, path string, info os.FileInfo) (value interface{}, err error) {
+func DefaultFormatValue(attr, path string, info os.FileInfo) (interface{}, error) {
+	return DefaultFormatValueContext(context.Background(), attr, path, info)
+}
+
+// DefaultFormatValueContext is DefaultFormatValue, which stops reading the
+// file (e.g. while hashing it or counting its lines) once ctx is done. The
+// bytes read are counted by the counter of ctx, if any (see WithReadCounter).
+func DefaultFormatValueContext(ctx context.Context, attr, path string,
+	info os.FileInfo) (value interface{}, err error) {
 	switch attr {
 	case "mode":
 		value = info.Mode()
 	case "name":
 		value = info.Name()
+	case "path":
+		value = path
 	case "size":
 		value = info.Size()
 	case "time":
 		value = info.ModTime().Format(time.Stamp)
 	case "hash":
-		if value, err = ComputeHash(info, path, FindHash("SHA1")()); value != nil {
+		if value, err = HashFileContext(ctx, info, path, "SHA1", -1); value != nil {
 			value = truncate(value.(string), defaultHashLength)
 		}
+	case "content":
+		// File contents are only selected through modifiers (e.g. MATCHES),
+		// so we avoid reading the file here.
+		value = nil
+	case "lines", "words", "chars":
+		var counts *Counts
+		if counts, err = CountContentContext(ctx, info, path); err != nil || counts == nil {
+			return nil, err
+		}
+		switch attr {
+		case "lines":
+			value = counts.Lines
+		case "words":
+			value = counts.Words
+		case "chars":
+			value = counts.Chars
+		}
+	case "mime":
+		value, err = detectMIME(ctx, info, path)
+	case "is_text", "is_binary":
+		if value, err = detectText(ctx, info, path); value != nil && attr == "is_binary" {
+			value = !value.(bool)
+		}
+	case "width", "height", "imgformat":
+		var config *ImageConfig
+		if config, err = decodeImageConfig(ctx, info, path); err != nil || config == nil {
+			return nil, err
+		}
+		switch attr {
+		case "width":
+			value = config.Width
+		case "height":
+			value = config.Height
+		case "imgformat":
+			value = config.Format
+		}
+	case "container":
+		value = Container(info)
+	case "target":
+		value = Target(info, path)
+	case "device":
+		value = Device(info)
+	case "fstype":
+		value = FSType(info)
 	default:
 		err = fmt.Errorf("unknown attribute %s", attr)
 	}
@@ -33,6 +33,20 @@ func TestTransform_Format(t *testing.T) {
 				err: nil,
 			},
 		},
+		{
+			params: &FormatParams{
+				Attribute: "size",
+				Path:      "path",
+				Info:      nil,
+				Value:     int64(300),
+				Name:      "format",
+				Args:      []string{"B"},
+			},
+			expected: Expected{
+				val: fmt.Sprintf("%fb", float64(300)),
+				err: nil,
+			},
+		},
 		{
 			params: &FormatParams{
 				Attribute: "size",
@@ -80,6 +94,39 @@ func TestTransform_Format(t *testing.T) {
 			},
 			expected: Expected{val: "path", err: nil},
 		},
+		{
+			params: &FormatParams{
+				Attribute: "imgformat",
+				Path:      "path",
+				Info:      nil,
+				Value:     nil,
+				Name:      "upper",
+				Args:      []string{},
+			},
+			expected: Expected{val: nil, err: nil},
+		},
+		{
+			params: &FormatParams{
+				Attribute: "fstype",
+				Path:      "path",
+				Info:      nil,
+				Value:     nil,
+				Name:      "lower",
+				Args:      []string{},
+			},
+			expected: Expected{val: nil, err: nil},
+		},
+		{
+			params: &FormatParams{
+				Attribute: "size",
+				Path:      "path",
+				Info:      nil,
+				Value:     int64(300),
+				Name:      "upper",
+				Args:      []string{},
+			},
+			expected: Expected{val: nil, err: &ErrNotImplemented{"upper", "size"}},
+		},
 	}
 
 	for _, c := range cases {
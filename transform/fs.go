@@ -0,0 +1,122 @@
+package transform
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FSFileInfo describes a file which is read through an fs.FS (e.g. a member of
+// an archive), rather than directly from the OS filesystem.
+type FSFileInfo struct {
+	os.FileInfo
+
+	// FS is the filesystem containing the file.
+	FS fs.FS
+
+	// FSPath is the path of the file within FS.
+	FSPath string
+
+	// Container is the path of the archive containing the file, or the empty
+	// string if the file isn't in an archive.
+	Container string
+}
+
+// openFile opens the file described by info for reading. Files described by
+// an FSFileInfo are opened through their FS, all others are opened from path.
+func openFile(info os.FileInfo, path string) (fs.File, error) {
+	if fi, ok := info.(*FSFileInfo); ok {
+		return fi.FS.Open(fi.FSPath)
+	}
+	return os.Open(path)
+}
+
+// Container returns the path of the archive containing the file described by
+// info, or nil if it isn't in an archive.
+func Container(info os.FileInfo) interface{} {
+	if fi, ok := info.(*FSFileInfo); ok && fi.Container != "" {
+		return fi.Container
+	}
+	return nil
+}
+
+// Target returns the destination of the symbolic link described by info (as
+// written in the link), or nil if it isn't a symbolic link. Links which were
+// followed while walking are still symbolic links.
+func Target(info os.FileInfo, path string) interface{} {
+	var (
+		target string
+		err    error
+	)
+	if fi, ok := info.(*FSFileInfo); ok {
+		lfs, ok := fi.FS.(LinkFS)
+		if !ok {
+			return nil
+		}
+		target, err = lfs.ReadLink(fi.FSPath)
+	} else {
+		target, err = os.Readlink(path)
+	}
+	if err != nil {
+		return nil
+	}
+	return target
+}
+
+// LinkFS is implemented by filesystems which support symbolic links. It has
+// the same methods as fs.ReadLinkFS, which isn't available in all supported
+// versions of Go.
+type LinkFS interface {
+	fs.FS
+
+	// ReadLink returns the destination of the named symbolic link.
+	ReadLink(name string) (string, error)
+
+	// Lstat returns a FileInfo describing the named file, without following
+	// symbolic links.
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// Lstat returns a FileInfo describing the named file in fsys. Symbolic links
+// aren't followed if fsys is a LinkFS.
+func Lstat(fsys fs.FS, name string) (fs.FileInfo, error) {
+	if lfs, ok := fsys.(LinkFS); ok {
+		return lfs.Lstat(name)
+	}
+	return fs.Stat(fsys, name)
+}
+
+// dirFS is an os.DirFS which supports symbolic links.
+type dirFS struct {
+	fs.FS
+	dir string
+}
+
+// DirFS returns a filesystem for the tree of files rooted at the directory
+// dir, like os.DirFS, which also implements LinkFS.
+func DirFS(dir string) LinkFS {
+	return &dirFS{FS: os.DirFS(dir), dir: dir}
+}
+
+func (d *dirFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(d.dir, filepath.FromSlash(name)), nil
+}
+
+func (d *dirFS) ReadLink(name string) (string, error) {
+	path, err := d.join("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	return os.Readlink(path)
+}
+
+func (d *dirFS) Lstat(name string) (fs.FileInfo, error) {
+	path, err := d.join("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(path)
+}
@@ -0,0 +1,88 @@
+package transform
+
+import (
+	"regexp"
+	"strings"
+)
+
+// GlobToRegexp translates the glob pattern into a regular expression, which
+// matches a whole slash-separated path once it's anchored (i.e. wrapped in `^`
+// and `$`). Patterns use the syntax of a .gitignore file, which is shared by
+// GLOB conditions, glob sources and exclusions:
+//
+// `*` matches any sequence of characters other than a slash, `?` matches any
+// single character other than a slash, and `[...]` matches a character class
+// (negated by a leading `!` or `^`). `**` matches across slashes when it's a
+// whole path component: a leading `**/` or inner `/**/` matches zero or more
+// directories, and a trailing `/**` matches everything inside. Any other `**`
+// is the same as `*`. A backslash escapes the following character.
+func GlobToRegexp(pattern string) string {
+	var b strings.Builder
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' && (i == 0 || runes[i-1] == '/') {
+				switch {
+				case i+2 == len(runes):
+					b.WriteString(".*")
+					i++
+					continue
+				case runes[i+2] == '/':
+					b.WriteString("(?:.*/)?")
+					i += 2
+					continue
+				}
+			}
+			for i+1 < len(runes) && runes[i+1] == '*' {
+				i++
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := classEnd(runes, i)
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := runes[i+1 : end]
+			i = end
+			b.WriteByte('[')
+			if len(class) > 0 && (class[0] == '!' || class[0] == '^') {
+				b.WriteByte('^')
+				class = class[1:]
+			}
+			b.WriteString(strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`).
+				Replace(string(class)))
+			b.WriteByte(']')
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return b.String()
+}
+
+// classEnd returns the index of the bracket closing the character class that
+// starts at runes[start], or -1 if it isn't closed. A closing bracket directly
+// after the opening bracket (or its negation) is part of the class.
+func classEnd(runes []rune, start int) int {
+	i := start + 1
+	if i < len(runes) && (runes[i] == '!' || runes[i] == '^') {
+		i++
+	}
+	if i < len(runes) && runes[i] == ']' {
+		i++
+	}
+	for ; i < len(runes); i++ {
+		if runes[i] == ']' {
+			return i
+		}
+	}
+	return -1
+}
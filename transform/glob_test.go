@@ -0,0 +1,48 @@
+package transform
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestGlob_GlobToRegexp(t *testing.T) {
+	type Input struct {
+		pattern, path string
+	}
+
+	type Case struct {
+		input    Input
+		expected bool
+	}
+
+	cases := []Case{
+		{input: Input{"*.go", "a.go"}, expected: true},
+		{input: Input{"*.go", "a/b.go"}, expected: false},
+		{input: Input{"src/**/*.go", "src/a.go"}, expected: true},
+		{input: Input{"src/**/*.go", "src/a/b/c.go"}, expected: true},
+		{input: Input{"src/**/*.go", "srca.go"}, expected: false},
+		{input: Input{"**/*.go", "a.go"}, expected: true},
+		{input: Input{"**/*.go", "a/b.go"}, expected: true},
+		{input: Input{"a/**", "a/b/c"}, expected: true},
+		{input: Input{"a/**", "a"}, expected: false},
+		{input: Input{"**.go", "a/b.go"}, expected: false},
+		{input: Input{"**.go", "b.go"}, expected: true},
+		{input: Input{"file.?", "file.c"}, expected: true},
+		{input: Input{"file.[ch]", "file.h"}, expected: true},
+		{input: Input{"file.[!ch]", "file.c"}, expected: false},
+		{input: Input{"file.[^ch]", "file.o"}, expected: true},
+		{input: Input{"[]a]", "]"}, expected: true},
+		{input: Input{"[a", "[a"}, expected: true},
+		{input: Input{`\*`, "*"}, expected: true},
+		{input: Input{`\*`, "a"}, expected: false},
+		{input: Input{"é?", "éa"}, expected: true},
+		{input: Input{"?", "é"}, expected: true},
+	}
+
+	for _, c := range cases {
+		re := regexp.MustCompile("^" + GlobToRegexp(c.input.pattern) + "$")
+		if actual := re.MatchString(c.input.path); actual != c.expected {
+			t.Fatalf("%v\nExpected %v\n     Got %v", c.input, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package transform
+
+import (
+	"bufio"
+	"context"
+	"image"
+	"os"
+
+	// Register the decoders used by image.DecodeConfig.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// ImageConfig holds the dimensions and format of an image.
+type ImageConfig struct {
+	Width, Height int64
+	Format        string
+}
+
+// DecodeImageConfig decodes the header of the image located at path, without
+// reading its pixel data. Supports PNG, JPEG, and GIF images. Returns nil for
+// directories and files which aren't (supported) images.
+func DecodeImageConfig(info os.FileInfo, path string) (*ImageConfig, error) {
+	return decodeImageConfig(context.Background(), info, path)
+}
+
+// decodeImageConfig is DecodeImageConfig, which stops reading the file once
+// ctx is done.
+func decodeImageConfig(ctx context.Context, info os.FileInfo, path string) (*ImageConfig, error) {
+	info, path, ok := resolveFile(info, path)
+	if !ok {
+		return nil, nil
+	}
+
+	f, err := openFile(info, path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	config, format, err := image.DecodeConfig(bufio.NewReader(newContextReader(ctx, f)))
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil {
+		// Either not an image, or a malformed one.
+		return nil, nil
+	}
+	return &ImageConfig{
+		Width:  int64(config.Width),
+		Height: int64(config.Height),
+		Format: format,
+	}, nil
+}
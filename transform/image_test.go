@@ -0,0 +1,76 @@
+package transform
+
+import (
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestImage_DecodeImageConfig(t *testing.T) {
+	type Case struct {
+		name     string
+		encode   func(io.Writer, image.Image) error
+		expected *ImageConfig
+	}
+
+	cases := []Case{
+		{
+			name:     "a.png",
+			encode:   png.Encode,
+			expected: &ImageConfig{Width: 3, Height: 2, Format: "png"},
+		},
+		{
+			name: "b.jpg",
+			encode: func(w io.Writer, m image.Image) error {
+				return jpeg.Encode(w, m, nil)
+			},
+			expected: &ImageConfig{Width: 3, Height: 2, Format: "jpeg"},
+		},
+		{
+			name: "c.gif",
+			encode: func(w io.Writer, m image.Image) error {
+				return gif.Encode(w, m, nil)
+			},
+			expected: &ImageConfig{Width: 3, Height: 2, Format: "gif"},
+		},
+		{
+			name: "d.png",
+			encode: func(w io.Writer, m image.Image) error {
+				_, err := w.Write([]byte("not an image"))
+				return err
+			},
+			expected: nil,
+		},
+	}
+
+	dir := t.TempDir()
+	for _, c := range cases {
+		path := filepath.Join(dir, c.name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+		}
+		if err := c.encode(f, image.NewRGBA(image.Rect(0, 0, 3, 2))); err != nil {
+			t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+		}
+		f.Close()
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+		}
+		actual, err := DecodeImageConfig(info, path)
+		if err != nil {
+			t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+		}
+		if !reflect.DeepEqual(c.expected, actual) {
+			t.Fatalf("%s\nExpected: %v\n     Got: %v", c.name, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package transform
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sniff returns the leading bytes of the file located at path, up to the
+// number of bytes required to detect its type. Returns false for
+// directories. Reading stops once ctx is done.
+func sniff(ctx context.Context, info os.FileInfo, path string) ([]byte, bool, error) {
+	info, path, ok := resolveFile(info, path)
+	if !ok {
+		return nil, false, nil
+	}
+
+	f, err := openFile(info, path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	head := make([]byte, binarySniffSize)
+	n, err := io.ReadFull(newContextReader(ctx, f), head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, false, err
+	}
+	return head[:n], true, nil
+}
+
+// DetectMIME returns the media type of the file located at path (without
+// parameters, e.g. `image/png`). The type is sniffed from the file's leading
+// bytes; if this is inconclusive, the extension is used instead. Returns
+// `inode/directory` for directories.
+func DetectMIME(info os.FileInfo, path string) (interface{}, error) {
+	return detectMIME(context.Background(), info, path)
+}
+
+// detectMIME is DetectMIME, which stops reading the file once ctx is done.
+func detectMIME(ctx context.Context, info os.FileInfo, path string) (interface{}, error) {
+	head, ok, err := sniff(ctx, info, path)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return "inode/directory", nil
+	}
+
+	// Sniffing only tells us if a text file is plain text, so defer to the
+	// extension table for a more precise type. We ignore non-textual types for
+	// text files, since common extensions are often mapped to unrelated types
+	// (e.g. `.mod`).
+	typ := http.DetectContentType(head)
+	byExt := mime.TypeByExtension(filepath.Ext(path))
+	switch {
+	case byExt == "":
+	case typ == "application/octet-stream":
+		typ = byExt
+	case strings.HasPrefix(typ, "text/plain") && isTextType(byExt):
+		typ = byExt
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(typ); err == nil {
+		typ = mediaType
+	}
+	return typ, nil
+}
+
+// DetectText returns true if the file located at path is a text file, i.e.
+// if its leading bytes don't contain a NUL byte. Returns nil for directories.
+func DetectText(info os.FileInfo, path string) (interface{}, error) {
+	return detectText(context.Background(), info, path)
+}
+
+// detectText is DetectText, which stops reading the file once ctx is done.
+func detectText(ctx context.Context, info os.FileInfo, path string) (interface{}, error) {
+	head, ok, err := sniff(ctx, info, path)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return !IsBinary(head), nil
+}
+
+// isTextType returns true iff typ is a textual media type.
+func isTextType(typ string) bool {
+	if strings.HasPrefix(typ, "text/") {
+		return true
+	}
+	for _, suffix := range []string{"json", "xml", "javascript", "yaml", "toml"} {
+		if strings.HasSuffix(strings.SplitN(typ, ";", 2)[0], suffix) {
+			return true
+		}
+	}
+	return false
+}
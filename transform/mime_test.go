@@ -0,0 +1,64 @@
+package transform
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMime_Detect(t *testing.T) {
+	type Case struct {
+		name    string
+		content string
+		mime    interface{}
+		text    interface{}
+	}
+
+	cases := []Case{
+		{name: "image", content: "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", mime: "image/png", text: false},
+		{name: "notes", content: "foo bar\n", mime: "text/plain", text: true},
+		{name: "page.html", content: "<html><body></body></html>", mime: "text/html", text: true},
+		{name: "blob", content: "\x00\x01\x02", mime: "application/octet-stream", text: false},
+		{name: "empty", content: "", mime: "text/plain", text: true},
+	}
+
+	dir := t.TempDir()
+	for _, c := range cases {
+		path := filepath.Join(dir, c.name)
+		if err := os.WriteFile(path, []byte(c.content), 0644); err != nil {
+			t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+		}
+
+		actual, err := DetectMIME(info, path)
+		if err != nil {
+			t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+		}
+		if !reflect.DeepEqual(c.mime, actual) {
+			t.Fatalf("%s\nExpected: %v\n     Got: %v", c.name, c.mime, actual)
+		}
+
+		actual, err = DetectText(info, path)
+		if err != nil {
+			t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+		}
+		if !reflect.DeepEqual(c.text, actual) {
+			t.Fatalf("%s\nExpected: %v\n     Got: %v", c.name, c.text, actual)
+		}
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatalf("\nExpected no error\n     Got: %s", err.Error())
+	}
+	if actual, _ := DetectMIME(info, dir); actual != "inode/directory" {
+		t.Fatalf("\nExpected: inode/directory\n     Got: %v", actual)
+	}
+	if actual, _ := DetectText(info, dir); actual != nil {
+		t.Fatalf("\nExpected: nil\n     Got: %v", actual)
+	}
+}
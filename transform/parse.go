@@ -1,8 +1,9 @@
 package transform
 
 import (
-	"hash"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
@@ -16,6 +17,10 @@ type ParseParams struct {
 
 	Name string
 	Args []string
+
+	// FS is the filesystem that paths in Value are read from. If nil, paths
+	// are read from the OS filesystem.
+	FS fs.FS
 }
 
 // Parse runs the associated modifier function for the provided parameters.
@@ -64,7 +69,7 @@ func Parse(p *ParseParams) (val interface{}, err error) {
 	case "LOWER":
 		val = lower(p.Value.(string))
 	case "SHA1":
-		val, err = p.hash(FindHash(p.Name)())
+		val, err = p.hash()
 	}
 
 	if err != nil {
@@ -102,19 +107,11 @@ func (p *ParseParams) formatSize() (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	switch strings.ToUpper(p.Args[0]) {
-	case "B":
-		size *= 1
-	case "KB":
-		size *= 1 << 10
-	case "MB":
-		size *= 1 << 20
-	case "GB":
-		size *= 1 << 30
-	default:
+	bytes, ok := sizeUnit(p.Args[0])
+	if !ok {
 		return nil, nil
 	}
-	return size, nil
+	return size * bytes, nil
 }
 
 // formatTime formats the time attribute. Valid arguments include `ISO`,
@@ -139,10 +136,26 @@ func (p *ParseParams) formatTime() (interface{}, error) {
 	return t, nil
 }
 
-func (p *ParseParams) hash(h hash.Hash) (interface{}, error) {
-	info, err := os.Stat(p.Value.(string))
+// hash computes the hash of the file located at the path held in p.Value. A
+// PREFIX argument restricts the hash to the leading bytes of the file.
+func (p *ParseParams) hash() (interface{}, error) {
+	_, limit, err := ParseHashArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	path := p.Value.(string)
+	if p.FS == nil {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		return HashFile(info, path, p.Name, limit)
+	}
+
+	name := filepath.ToSlash(path)
+	info, err := fs.Stat(p.FS, name)
 	if err != nil {
 		return nil, err
 	}
-	return ComputeHash(info, p.Value.(string), h)
+	return HashFile(&FSFileInfo{FileInfo: info, FS: p.FS, FSPath: name}, path, p.Name, limit)
 }